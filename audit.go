@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DefaultAuditFile is where the audit log is written when the config doesn't
+// say otherwise.
+const DefaultAuditFile = "audit.jsonl"
+
+// ActionAudit is the permission needed to read the audit log.
+const ActionAudit = "audit"
+
+// Outcomes of audited actions.
+const (
+	OutcomeOK     = "ok"
+	OutcomeDenied = "denied"
+	OutcomeError  = "error"
+)
+
+// auditLimit is how many entries an audit query shows.
+const auditLimit = 20
+
+// auditEntry is one line of the audit log.
+type auditEntry struct {
+	Time       time.Time         `json:"time"`
+	UserID     string            `json:"userId"`
+	Username   string            `json:"username"`
+	GuildID    string            `json:"guildId,omitempty"`
+	ChannelID  string            `json:"channelId"`
+	Action     string            `json:"action"`
+	Job        string            `json:"job"`
+	Build      int               `json:"build,omitempty"`
+	QueueID    int64             `json:"queueId,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Outcome    string            `json:"outcome"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// auditLog appends entries as JSON lines to a file.
+type auditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// record appends entry to the log.
+func (a *auditLog) record(entry auditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// auditQuery selects audit entries. Empty fields match everything.
+type auditQuery struct {
+	Job    string
+	UserID string
+	Since  time.Time
+}
+
+func (query auditQuery) matches(entry auditEntry) bool {
+	if query.Job != "" && !globMatch(query.Job, entry.Job) {
+		return false
+	}
+	if query.UserID != "" && entry.UserID != query.UserID {
+		return false
+	}
+	return entry.Time.After(query.Since)
+}
+
+// search returns the newest entries matching query, newest first, at most
+// limit of them.
+func (a *auditLog) search(query auditQuery, limit int) ([]auditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.Open(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var found []auditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if query.matches(entry) {
+			found = append(found, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(found) > limit {
+		found = found[len(found)-limit:]
+	}
+	for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+		found[i], found[j] = found[j], found[i]
+	}
+	return found, nil
+}
+
+// audit records that who performed action on job with the outcome err.
+// entry carries the action specific fields.
+func (bot *Bot) audit(who actor, entry auditEntry, err error) {
+	entry.Time = time.Now().UTC()
+	entry.UserID = who.User.ID
+	entry.Username = who.User.Username
+	entry.GuildID = who.GuildID
+	entry.ChannelID = who.ChannelID
+	if entry.Outcome == "" {
+		entry.Outcome = OutcomeOK
+		if err != nil {
+			entry.Outcome = OutcomeError
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if err := bot.auditLog.record(entry); err != nil {
+		Logger.Println("Error writing audit log:", err)
+	}
+}
+
+// parseSince parses how far back an audit query goes: a duration such as
+// 90m, 24h or 7d, or a date as 2006-01-02.
+func parseSince(value string) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+		return time.Now().Add(-duration), nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s', expected e.g. 24h, 7d or 2006-01-02", value)
+}
+
+// mentionedUser returns the user ID in a mention such as <@123> or <@!123>,
+// or a bare user ID.
+func mentionedUser(value string) (string, bool) {
+	id := value
+	if strings.HasPrefix(id, "<@") && strings.HasSuffix(id, ">") {
+		id = strings.TrimPrefix(id[2:len(id)-1], "!")
+	}
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil || len(id) < 15 {
+		return "", false
+	}
+	return id, true
+}
+
+// parseAuditArgs reads the arguments of !audit, which may come in any order:
+// a user mention or ID, a time and a job pattern.
+func parseAuditArgs(args []string) (auditQuery, error) {
+	var query auditQuery
+	for _, arg := range args {
+		if id, ok := mentionedUser(arg); ok {
+			query.UserID = id
+			continue
+		}
+		if since, err := parseSince(arg); err == nil {
+			query.Since = since
+			continue
+		}
+		if query.Job != "" {
+			return query, fmt.Errorf("more than one job given: '%s' and '%s'", query.Job, arg)
+		}
+		query.Job = arg
+	}
+	return query, nil
+}
+
+// auditEmbed renders audit entries, newest first.
+func auditEmbed(query auditQuery, entries []auditEntry) *discordgo.MessageEmbed {
+	var lines strings.Builder
+	for _, entry := range entries {
+		outcome := "✅"
+		switch entry.Outcome {
+		case OutcomeDenied:
+			outcome = "🚫"
+		case OutcomeError:
+			outcome = "❌"
+		}
+		lines.WriteString(fmt.Sprintf("%s <t:%d:f> <@%s> %s '%s'", outcome, entry.Time.Unix(), entry.UserID, entry.Action, entry.Job))
+		if entry.Build > 0 {
+			lines.WriteString(fmt.Sprintf(" #%d", entry.Build))
+		}
+		if len(entry.Parameters) > 0 {
+			lines.WriteString(" " + formatParameterValues(entry.Parameters))
+		}
+		if entry.Error != "" {
+			lines.WriteString(": " + entry.Error)
+		}
+		lines.WriteString("\n")
+	}
+	if len(entries) == 0 {
+		lines.WriteString("No matching actions")
+	}
+
+	var filters []string
+	if query.Job != "" {
+		filters = append(filters, "job "+query.Job)
+	}
+	if query.UserID != "" {
+		filters = append(filters, "user <@"+query.UserID+">")
+	}
+	if !query.Since.IsZero() {
+		filters = append(filters, fmt.Sprintf("since <t:%d:f>", query.Since.Unix()))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Audit log",
+		Description: truncate(lines.String(), maxEmbedDescription),
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Newest %d actions", auditLimit)},
+	}
+	if len(filters) > 0 {
+		embed.Fields = []*discordgo.MessageEmbedField{{Name: "Filters", Value: truncate(strings.Join(filters, ", "), 1024)}}
+	}
+	return embed
+}
+
+// auditMessage runs an audit query for who and renders the result. The log
+// shows everyone's parameters, so unlike other actions reading it needs a
+// permissions rule that grants it even when none are configured.
+func (bot *Bot) auditMessage(who actor, query auditQuery) (*discordgo.MessageEmbed, error) {
+	scope := query.Job
+	if scope == "" {
+		scope = "*"
+	}
+	if len(bot.Config.Permissions) == 0 {
+		Logger.Printf("Denied %s on %s for %s (%s), no permissions configured\n", ActionAudit, scope, who.User.Username, who.User.ID)
+		bot.audit(who, auditEntry{Action: ActionAudit, Job: scope, Outcome: OutcomeDenied}, nil)
+		return nil, fmt.Errorf("🚫 %s, reading the audit log needs a rule granting the audit action in permissions", who.User.Mention())
+	}
+	if err := bot.authorize(who, ActionAudit, scope); err != nil {
+		return nil, err
+	}
+
+	entries, err := bot.auditLog.search(query, auditLimit)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading audit log: %v", err)
+	}
+	return auditEmbed(query, entries), nil
+}
+
+// handleAuditCommand is the /audit slash command.
+func (bot *Bot) handleAuditCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	options := commandOptions(interaction)
+
+	var query auditQuery
+	if option, ok := options["job"]; ok {
+		query.Job = bot.qualifyJob(option.StringValue(), interaction.GuildID, interaction.ChannelID)
+	}
+	if option, ok := options["user"]; ok {
+		query.UserID = option.UserValue(nil).ID
+	}
+	if option, ok := options["since"]; ok {
+		since, err := parseSince(option.StringValue())
+		if err != nil {
+			bot.respondEphemeral(session, interaction, err.Error())
+			return
+		}
+		query.Since = since
+	}
+
+	embed, err := bot.auditMessage(interactionActor(interaction), query)
+	if err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+	err = session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		Logger.Println("Error responding to interaction:", err)
+	}
+}
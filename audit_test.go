@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestAuditMessageNeedsPermission(t *testing.T) {
+	Logger = log.New(io.Discard, "", 0)
+	auditor := actor{ChannelID: "channel", User: &discordgo.User{ID: "100000000000000001", Username: "auditor"}}
+	other := actor{ChannelID: "channel", User: &discordgo.User{ID: "100000000000000002", Username: "other"}}
+
+	tests := []struct {
+		name        string
+		permissions []PermissionRule
+		who         actor
+		allowed     bool
+	}{
+		{"no permissions configured", nil, auditor, false},
+		{"granted", []PermissionRule{{Users: []string{auditor.User.ID}, Actions: []string{ActionAudit}, Jobs: []string{"*"}}}, auditor, true},
+		{"granted to someone else", []PermissionRule{{Users: []string{auditor.User.ID}, Actions: []string{ActionAudit}, Jobs: []string{"*"}}}, other, false},
+		{"other actions only", []PermissionRule{{Users: []string{auditor.User.ID}, Actions: []string{ActionRun, ActionProceed}, Jobs: []string{"*"}}}, auditor, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bot := &Bot{
+				Config:   &Config{Permissions: test.permissions},
+				auditLog: &auditLog{path: filepath.Join(t.TempDir(), "audit.jsonl")},
+			}
+			_, err := bot.auditMessage(test.who, auditQuery{})
+			if (err == nil) != test.allowed {
+				t.Errorf("auditMessage() = %v, want allowed %t", err, test.allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Actions that change the state of Jenkins and are subject to permissions.
+const (
+	ActionRun       = "run"
+	ActionRunParams = "runparams"
+	ActionProceed   = "proceed"
+	ActionAbort     = "abort"
+)
+
+// PermissionRule grants the listed actions on jobs matching any of Jobs to
+// the listed users and members of the listed roles. Roles may be given by ID
+// or name, users by ID, and "*" matches any action. Job patterns are globs
+// where '*' matches any run of characters, e.g. "deploy-*". A rule listing
+// Instances only applies to jobs on those Jenkins instances.
+type PermissionRule struct {
+	Roles     []string `json:"roles"`
+	Users     []string `json:"users"`
+	Actions   []string `json:"actions"`
+	Jobs      []string `json:"jobs"`
+	Instances []string `json:"instances"`
+}
+
+// actor is the Discord user asking for an action, with the guild and roles it
+// was asked from. Member is nil in DMs. MessageID is the message holding a
+// legacy command and is empty for interactions.
+type actor struct {
+	GuildID   string
+	ChannelID string
+	MessageID string
+	User      *discordgo.User
+	Member    *discordgo.Member
+}
+
+// interactionActor returns the actor behind an interaction.
+func interactionActor(interaction *discordgo.InteractionCreate) actor {
+	return actor{GuildID: interaction.GuildID, ChannelID: interaction.ChannelID, User: interactionUser(interaction), Member: interaction.Member}
+}
+
+// messageActor returns the actor behind a legacy message command.
+func messageActor(message *discordgo.MessageCreate) actor {
+	return actor{GuildID: message.GuildID, ChannelID: message.ChannelID, MessageID: message.ID, User: message.Author, Member: message.Member}
+}
+
+// authorize returns an error suitable for showing to the user when who may
+// not perform action on job.
+func (bot *Bot) authorize(who actor, action, job string) error {
+	if len(bot.Config.Permissions) == 0 {
+		return nil
+	}
+
+	instance, jobName := splitInstance(job)
+	if instance == "" {
+		instance = bot.Config.DefaultJenkins
+	}
+
+	for _, rule := range bot.Config.Permissions {
+		if rule.allowsAction(action) && rule.allowsInstance(instance) && rule.allowsJob(jobName) && bot.ruleAppliesTo(rule, who) {
+			return nil
+		}
+	}
+
+	Logger.Printf("Denied %s on %s for %s (%s)\n", action, job, who.User.Username, who.User.ID)
+	bot.audit(who, auditEntry{Action: action, Job: job, Outcome: OutcomeDenied}, nil)
+	return fmt.Errorf("🚫 %s, you are not allowed to %s '%s'", who.User.Mention(), action, job)
+}
+
+func (rule PermissionRule) allowsAction(action string) bool {
+	for _, allowed := range rule.Actions {
+		if allowed == "*" || allowed == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule PermissionRule) allowsInstance(instance string) bool {
+	return len(rule.Instances) == 0 || containsString(rule.Instances, instance)
+}
+
+func (rule PermissionRule) allowsJob(job string) bool {
+	for _, pattern := range rule.Jobs {
+		if globMatch(pattern, job) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleAppliesTo reports whether who is one of the rule's users or has one of
+// its roles.
+func (bot *Bot) ruleAppliesTo(rule PermissionRule, who actor) bool {
+	for _, user := range rule.Users {
+		if user == who.User.ID {
+			return true
+		}
+	}
+
+	if who.Member == nil {
+		return false
+	}
+	for _, roleID := range who.Member.Roles {
+		roleName := ""
+		if role, err := bot.Session.State.Role(who.GuildID, roleID); err == nil {
+			roleName = role.Name
+		}
+		for _, allowed := range rule.Roles {
+			allowed = strings.TrimPrefix(allowed, "@")
+			if allowed == roleID || (roleName != "" && allowed == roleName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globMatch reports whether name matches pattern, where '*' matches any run
+// of characters (including '/') and '?' matches a single character.
+func globMatch(pattern, name string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	matched, err := regexp.MatchString("^"+expr+"$", name)
+	return err == nil && matched
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	jobCacheRefresh = 2 * time.Minute
+	// maxChoices is the number of autocomplete choices Discord accepts.
+	maxChoices = 25
+)
+
+// jobCache holds the job names used for autocomplete. Autocomplete must be
+// answered within three seconds, so the list is refreshed in the background
+// instead of fetched from Jenkins per keystroke.
+type jobCache struct {
+	mu    sync.RWMutex
+	names []string
+}
+
+func (cache *jobCache) get() []string {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	return cache.names
+}
+
+func (cache *jobCache) set(names []string) {
+	cache.mu.Lock()
+	cache.names = names
+	cache.mu.Unlock()
+}
+
+// refreshJobCache keeps the job name cache up to date. It never returns and is
+// meant to be run in its own goroutine.
+func (bot *Bot) refreshJobCache() {
+	for {
+		names, err := bot.fetchJenkinsJobs()
+		if err != nil {
+			Logger.Println("Error refreshing job cache:", err)
+		} else {
+			bot.jobs.set(names)
+		}
+		time.Sleep(jobCacheRefresh)
+	}
+}
+
+// autocompletePipeline answers autocomplete requests for the pipeline option.
+func (bot *Bot) autocompletePipeline(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	var query string
+	for _, option := range interaction.ApplicationCommandData().Options {
+		if option.Focused {
+			query = option.StringValue()
+		}
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, name := range matchJobNames(bot.jobs.get(), query) {
+		// Discord rejects choice values longer than 100 characters
+		if len(name) > 100 {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: name, Value: name})
+		if len(choices) == maxChoices {
+			break
+		}
+	}
+
+	err := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		Logger.Println("Error responding to autocomplete:", err)
+	}
+}
+
+// matchJobNames filters names by query, case-insensitively. Names starting
+// with the query come first, then names with a word starting with it, then
+// names containing its characters in order, so "dpl" still finds "deploy".
+func matchJobNames(names []string, query string) []string {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	type match struct {
+		name string
+		rank int
+	}
+	var matches []match
+
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(lower, query):
+			matches = append(matches, match{name, 0})
+		case hasWordPrefix(lower, query):
+			matches = append(matches, match{name, 1})
+		case isSubsequence(lower, query):
+			matches = append(matches, match{name, 2})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].rank != matches[j].rank {
+			return matches[i].rank < matches[j].rank
+		}
+		return strings.ToLower(matches[i].name) < strings.ToLower(matches[j].name)
+	})
+
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = m.name
+	}
+	return result
+}
+
+// hasWordPrefix reports whether any word of name starts with query, where
+// words are separated by spaces, dashes, underscores, dots or slashes.
+func hasWordPrefix(name, query string) bool {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return strings.ContainsRune(" -_./", r)
+	})
+	for _, word := range words {
+		if strings.HasPrefix(word, query) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubsequence reports whether the characters of query appear in name in order.
+func isSubsequence(name, query string) bool {
+	remaining := []rune(query)
+	for _, r := range name {
+		if len(remaining) == 0 {
+			break
+		}
+		if r == remaining[0] {
+			remaining = remaining[1:]
+		}
+	}
+	return len(remaining) == 0
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,11 +8,12 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"bot/jenkins"
+
 	"github.com/bwmarrin/discordgo"
 	"github.com/joho/godotenv"
 	"golang.org/x/exp/rand"
@@ -22,12 +22,28 @@ import (
 type Bot struct {
 	Session *discordgo.Session
 	Logger  *log.Logger
+	// GuildID limits slash command registration to a single guild when set.
+	GuildID string
+	Config  *Config
+
+	// instances holds a client per configured Jenkins instance.
+	instances     map[string]*jenkins.Client
+	instanceNames []string
+	// identities holds the Jenkins accounts users linked, nil when
+	// IDENTITY_KEY is not set.
+	identities *identityStore
+	auditLog   *auditLog
+
+	jobs jobCache
+	tree jobTreeCache
 }
 
 var (
-	JenkinsToken string
-	JenkinsURL   string
-	Logger       *log.Logger
+	JenkinsToken     string
+	JenkinsTokenFile string
+	JenkinsURL       string
+	JenkinsUser      string
+	Logger           *log.Logger
 )
 
 var (
@@ -39,6 +55,8 @@ var (
 
 const (
 	LogFile = "bot.log"
+	// jobTreeDepth is how many levels of folders are searched for jobs.
+	jobTreeDepth = 4
 )
 
 func main() {
@@ -62,9 +80,31 @@ func main() {
 
 	// Use the loaded environment variables
 	JenkinsToken = os.Getenv("JENKINS_TOKEN")
+	JenkinsTokenFile = os.Getenv("JENKINS_TOKEN_FILE")
 	JenkinsURL = os.Getenv("JENKINS_URL")
+	JenkinsUser = os.Getenv("JENKINS_USER")
+	if JenkinsUser == "" {
+		JenkinsUser = "jenkins"
+	}
 	DiscordToken := os.Getenv("DISCORD_TOKEN")
 
+	configFile := os.Getenv("BOT_CONFIG")
+	if configFile == "" {
+		configFile = DefaultConfigFile
+	}
+	config, err := loadConfig(configFile)
+	if err != nil {
+		Logger.Println("Error loading config:", err)
+		return
+	}
+	if _, _, err := config.Stop.timeouts(); err != nil {
+		Logger.Println("Error loading config:", err)
+		return
+	}
+	if len(config.Permissions) == 0 {
+		Logger.Println("No permissions configured, every user may run, proceed and abort any job")
+	}
+
 	discord, err := discordgo.New("Bot " + DiscordToken)
 	if err != nil {
 		Logger.Println("Error creating Discord session:", err)
@@ -74,9 +114,43 @@ func main() {
 	bot := Bot{
 		Session: discord,
 		Logger:  Logger,
+		GuildID: os.Getenv("DISCORD_GUILD_ID"),
+		Config:  config,
+	}
+	if err := bot.setupInstances(); err != nil {
+		Logger.Println("Error setting up Jenkins instances:", err)
+		return
+	}
+
+	bot.auditLog = &auditLog{path: config.AuditLog}
+	if bot.auditLog.path == "" {
+		bot.auditLog.path = DefaultAuditFile
+	}
+
+	if key := os.Getenv("IDENTITY_KEY"); key != "" {
+		file := config.Identities.File
+		if file == "" {
+			file = DefaultIdentityFile
+		}
+		bot.identities, err = loadIdentityStore(file, key)
+		if err != nil {
+			Logger.Println("Error loading linked Jenkins accounts:", err)
+			return
+		}
+	} else {
+		Logger.Println("IDENTITY_KEY is not set, users can't link their Jenkins accounts")
 	}
 
-	discord.AddHandler(bot.newMsg)
+	discord.AddHandler(bot.ready)
+	discord.AddHandler(bot.interactionCreate)
+
+	// The legacy "!" commands read raw message text, which needs the
+	// privileged message content intent, so they are opt-in.
+	if os.Getenv("LEGACY_COMMANDS") == "true" {
+		discord.Identify.Intents |= discordgo.IntentsMessageContent
+		discord.AddHandler(bot.newMsg)
+		Logger.Println("Legacy ! commands enabled")
+	}
 
 	err = discord.Open()
 	if err != nil {
@@ -86,6 +160,18 @@ func main() {
 
 	Logger.Println("Bot is connected to Discord")
 
+	go bot.refreshJobCache()
+
+	if secret := os.Getenv("WEBHOOK_SECRET"); secret != "" {
+		addr := os.Getenv("WEBHOOK_ADDR")
+		if addr == "" {
+			addr = DefaultWebhookAddr
+		}
+		go bot.startWebhookServer(addr, secret)
+	} else {
+		Logger.Println("WEBHOOK_SECRET is not set, not listening for build notifications")
+	}
+
 	defer discord.Close()
 
 	select {}
@@ -102,7 +188,7 @@ func (bot *Bot) newMsg(session *discordgo.Session, message *discordgo.MessageCre
 		session.ChannelMessageSend(message.ChannelID, "time")
 		gifURL, err := getGIFURL("steak", 50)
 		if err != nil {
-			Logger.Println("Failed to fetch reek gif, %s", err)
+			Logger.Printf("Failed to fetch reek gif, %s\n", err)
 			return
 		}
 		session.ChannelMessageSend(message.ChannelID, gifURL)
@@ -110,7 +196,7 @@ func (bot *Bot) newMsg(session *discordgo.Session, message *discordgo.MessageCre
 		session.ChannelMessageSend(message.ChannelID, "Austin TRAN Daniels")
 		gifURL, err := getGIFURL("theon-greyjoy-reek", 20)
 		if err != nil {
-			Logger.Println("Failed to fetch reek gif, %s", err)
+			Logger.Printf("Failed to fetch reek gif, %s\n", err)
 			return
 		}
 		session.ChannelMessageSend(message.ChannelID, gifURL)
@@ -118,7 +204,7 @@ func (bot *Bot) newMsg(session *discordgo.Session, message *discordgo.MessageCre
 		session.ChannelMessageSend(message.ChannelID, "mayte")
 		gifURL, err := getGIFURL("crikey", 20)
 		if err != nil {
-			Logger.Println("Failed to fetch crikey gif, %s", err)
+			Logger.Printf("Failed to fetch crikey gif, %s\n", err)
 			return
 		}
 		session.ChannelMessageSend(message.ChannelID, gifURL)
@@ -135,20 +221,32 @@ func (bot *Bot) newMsg(session *discordgo.Session, message *discordgo.MessageCre
 		}
 		session.ChannelMessageSend(message.ChannelID, gifURL)
 	case strings.Contains(message.Content, "!list"):
-		jobList, err := bot.getJenkinsJobList()
+		filter, err := parseListFilter(message.Content[strings.Index(message.Content, "!list")+len("!list"):])
+		if err != nil {
+			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error parsing !list filters: %v", err))
+			return
+		}
+		if filter.Instance == "" {
+			filter.Instance = bot.channelInstance(message.GuildID, message.ChannelID)
+		}
+
+		list, err := bot.jobListMessage(filter, 0)
 		if err != nil {
 			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error fetching Jenkins job list: %v", err))
 			return
 		}
-		session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Jenkins Job List:\n%s", jobList))
+		session.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{
+			Embeds:     list.Embeds,
+			Components: list.Components,
+		})
 	case strings.HasPrefix(message.Content, "!runparams"):
 		// Handle !runparams command
-		pipelineName, err := bot.runPipelineWithParameters(message.Content)
+		pipelineName, queueID, err := bot.runPipelineWithParameters(messageActor(message), message.Content)
 		if err != nil {
 			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error handling !runparams: %v", err))
 			return
 		}
-		session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Jenkins pipeline '%s' triggered successfully!", pipelineName))
+		bot.announceTrigger(messageActor(message), pipelineName, queueID)
 	case strings.HasPrefix(message.Content, "!run"):
 		// Extract the pipeline name from the message
 		parts := strings.Fields(message.Content)
@@ -156,571 +254,456 @@ func (bot *Bot) newMsg(session *discordgo.Session, message *discordgo.MessageCre
 			session.ChannelMessageSend(message.ChannelID, "Usage: !run <pipeline_name>")
 			return
 		}
-		pipelineName := strings.Join(parts[1:], " ")
+		pipelineName := bot.qualifyJob(strings.Join(parts[1:], " "), message.GuildID, message.ChannelID)
+		if err := bot.authorize(messageActor(message), ActionRun, pipelineName); err != nil {
+			session.ChannelMessageSend(message.ChannelID, err.Error())
+			return
+		}
 
 		// Trigger the Jenkins pipeline
-		err := bot.triggerJenkinsPipeline(pipelineName)
+		queueID, err := bot.triggerJenkinsPipeline(messageActor(message), pipelineName)
 		if err != nil {
 			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error triggering Jenkins pipeline '%s': %v", pipelineName, err))
 			return
 		}
-		session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Jenkins pipeline '%s' triggered successfully!", pipelineName))
+		bot.announceTrigger(messageActor(message), pipelineName, queueID)
 	case strings.HasPrefix(message.Content, "!proceed"):
-		// Extract the pipeline name from the message
-		parts := strings.Fields(message.Content)
+		// Extract the pipeline name, build number and input ID from the first
+		// line, and the parameters of the input from the ones after it
+		lines := strings.Split(message.Content, "\n")
+		parts := strings.Fields(lines[0])
 		if len(parts) < 2 {
-			session.ChannelMessageSend(message.ChannelID, "Usage: !proceed <pipeline_name>")
+			session.ChannelMessageSend(message.ChannelID, "Usage: !proceed <pipeline_name> [build [input_id]]\n[parameterKey parameterValue]...")
+			return
+		}
+		pipelineName, number, inputID := parseInputTarget(parts[1:])
+		pipelineName = bot.qualifyJob(pipelineName, message.GuildID, message.ChannelID)
+		if err := bot.authorize(messageActor(message), ActionProceed, pipelineName); err != nil {
+			session.ChannelMessageSend(message.ChannelID, err.Error())
+			return
+		}
+		parameters, err := parseParameterLines(lines[1:])
+		if err != nil {
+			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error handling !proceed: %v", err))
 			return
 		}
-		pipelineName := strings.Join(parts[1:], " ")
 
-		// Proceed the Jenkins pipeline
-		err := bot.proceedJenkinsPipeline(pipelineName)
+		// Proceed the Jenkins pipeline, or ask which input when it is ambiguous
+		// and for the parameters when the input wants some but got none
+		choices, err := bot.proceedJenkinsPipeline(messageActor(message), pipelineName, number, inputID, parameters)
 		if err != nil {
 			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error proceeding Jenkins pipeline '%s': %v", pipelineName, err))
 			return
 		}
+		if len(choices) > 0 {
+			content, components := inputChoices(ActionProceed, pipelineName, choices)
+			session.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{Content: content, Components: components})
+			return
+		}
 		session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Jenkins pipeline '%s' proceeded successfully!", pipelineName))
 	case strings.HasPrefix(message.Content, "!abort"):
-		// Extract the pipeline name from the message
+		// Extract the pipeline name, build number and input ID from the message
 		parts := strings.Fields(message.Content)
 		if len(parts) < 2 {
-			session.ChannelMessageSend(message.ChannelID, "Usage: !abort <pipeline_name>")
+			session.ChannelMessageSend(message.ChannelID, "Usage: !abort <pipeline_name> [build [input_id]]")
+			return
+		}
+		pipelineName, number, inputID := parseInputTarget(parts[1:])
+		pipelineName = bot.qualifyJob(pipelineName, message.GuildID, message.ChannelID)
+		if err := bot.authorize(messageActor(message), ActionAbort, pipelineName); err != nil {
+			session.ChannelMessageSend(message.ChannelID, err.Error())
 			return
 		}
-		pipelineName := strings.Join(parts[1:], " ")
 
-		// Abort the Jenkins pipeline
-		err := bot.abortJenkinsPipeline(pipelineName)
+		// Abort the Jenkins pipeline, or ask which input when it is ambiguous
+		choices, err := bot.abortJenkinsPipeline(messageActor(message), pipelineName, number, inputID)
 		if err != nil {
 			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error aborting Jenkins pipeline '%s': %v", pipelineName, err))
 			return
 		}
+		if len(choices) > 0 {
+			content, components := inputChoices(ActionAbort, pipelineName, choices)
+			session.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{Content: content, Components: components})
+			return
+		}
 		session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Jenkins pipeline '%s' aborted", pipelineName))
 	case strings.HasPrefix(message.Content, "!parameters"):
-		// Extract the pipeline name from the message
+		// Extract the pipeline name and optional build number from the message
 		parts := strings.Fields(message.Content)
 		if len(parts) < 2 {
-			session.ChannelMessageSend(message.ChannelID, "Usage: !parameters <pipeline_name>")
+			session.ChannelMessageSend(message.ChannelID, "Usage: !parameters <pipeline_name> [build]")
 			return
 		}
-		pipelineName := strings.Join(parts[1:], " ")
+		pipelineName, number := parsePipelineAndBuild(parts[1:])
+		pipelineName = bot.qualifyJob(pipelineName, message.GuildID, message.ChannelID)
 
-		// Send parameters from last build
-		parameters, err := bot.fetchJenkinsJobParameters(pipelineName)
+		// Send parameters from the build, the last one by default
+		parameters, err := bot.fetchJenkinsJobParameters(pipelineName, number)
 		if err != nil {
 			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error fetching parameters for '%s': %v", pipelineName, err))
 			return
 		}
 		session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Parameters from previous run:%s", parameters))
-	case strings.HasPrefix(message.Content, "!help"):
-		// Provide help information for each command
-		helpMsg := "Available Commands:\n" +
-			"!list ---------------------------> Fetches and displays the Jenkins job list\n" +
-			"!run <pipeline_name> ---------> Triggers a Jenkins pipeline with the specified name\n" +
-			"!proceed <pipeline_name> ----> Proceeds the current stage of a pipeline\n" +
-			"!abort <pipeline_name> -------> Aborts the current stage of a pipeline\n" +
-			"!parameters <pipeline_name> -> Fetches the parameters from the previous build\n\n" +
-			"!runparams\n<pipeline_name\n\nparameterKey parameterValue1\n\nparameterKey2 Parameter value 2"
-		session.ChannelMessageSend(message.ChannelID, helpMsg)
-	}
-
-}
-
-// getJenkinsJobList retrieves the list of Jenkins jobs, their statuses, and other details.
-func (bot *Bot) getJenkinsJobList() (string, error) {
-	jobList, err := bot.fetchJenkinsJobs()
-	if err != nil {
-		return "", err
-	}
-
-	var result strings.Builder
-
-	for _, job := range jobList {
-		// Replace spaces in job name with %20
-		jobName := strings.ReplaceAll(job, " ", "%20")
+	case strings.HasPrefix(message.Content, "!schema"):
+		// Extract the pipeline name from the message
+		parts := strings.Fields(message.Content)
+		if len(parts) < 2 {
+			session.ChannelMessageSend(message.ChannelID, "Usage: !schema <pipeline_name>")
+			return
+		}
+		pipelineName := bot.qualifyJob(strings.Join(parts[1:], " "), message.GuildID, message.ChannelID)
 
-		// Fetch details for each job
-		jobStatus, err := bot.fetchJenkinsJobStatus(jobName)
-		Logger.Println("Job Name: ", jobName, "Job Status: ", jobStatus)
+		// Send the parameters the pipeline declares
+		schema, err := bot.fetchJenkinsParameterSchema(pipelineName)
 		if err != nil {
-			Logger.Println("Got some error when getting a job status: ", err)
+			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error fetching parameter definitions for '%s': %v", pipelineName, err))
+			return
 		}
+		session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Parameters of '%s':%s", pipelineName, schema))
+	case strings.HasPrefix(message.Content, "!logs"):
+		// Extract the pipeline name and optional build number from the message
+		parts := strings.Fields(message.Content)
+		if len(parts) < 2 {
+			session.ChannelMessageSend(message.ChannelID, "Usage: !logs <pipeline_name> [build]")
+			return
+		}
+		pipelineName, number := parsePipelineAndBuild(parts[1:])
+		pipelineName = bot.qualifyJob(pipelineName, message.GuildID, message.ChannelID)
 
-		// Append formatted job information to the result
-		result.WriteString(fmt.Sprintf("%s **%s**\n", jobStatus, job))
-	}
-
-	return result.String(), nil
-}
-
-// fetchJenkinsJobs retrieves the list of Jenkins job names.
-func (bot *Bot) fetchJenkinsJobs() ([]string, error) {
-	url := JenkinsURL + "/api/json?tree=jobs[name]"
+		number, err := bot.resolveBuildNumber(pipelineName, number)
+		if err != nil {
+			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error fetching build of '%s': %v", pipelineName, err))
+			return
+		}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+		// Stream the log into a thread under the command
+		go bot.startLogThread(message.ChannelID, message.ID, pipelineName, number)
+	case strings.HasPrefix(message.Content, "!stages"):
+		// Extract the pipeline name and optional build number from the message
+		parts := strings.Fields(message.Content)
+		if len(parts) < 2 {
+			session.ChannelMessageSend(message.ChannelID, "Usage: !stages <pipeline_name> [build]")
+			return
+		}
+		pipelineName, number := parsePipelineAndBuild(parts[1:])
+		pipelineName = bot.qualifyJob(pipelineName, message.GuildID, message.ChannelID)
 
-	// Set Jenkins authorization header
-	authHeader := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte("jenkins:"+JenkinsToken)))
-	req.Header.Set("Authorization", authHeader)
+		number, err := bot.resolveBuildNumber(pipelineName, number)
+		if err != nil {
+			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error fetching build of '%s': %v", pipelineName, err))
+			return
+		}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+		// Send the stage view and keep it updated while the build runs
+		embed, running, err := bot.fetchStagesEmbed(pipelineName, number)
+		if err != nil {
+			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error fetching stages of '%s' #%d: %v", pipelineName, number, err))
+			return
+		}
+		msg, err := session.ChannelMessageSendEmbed(message.ChannelID, embed)
+		if err != nil {
+			Logger.Println("Error sending stage view:", err)
+			return
+		}
+		if running {
+			go bot.watchStages(message.ChannelID, msg.ID, pipelineName, number)
+		}
+	case strings.HasPrefix(message.Content, "!stop"):
+		// Extract the pipeline name and optional build number from the message
+		parts := strings.Fields(message.Content)
+		if len(parts) < 2 {
+			session.ChannelMessageSend(message.ChannelID, "Usage: !stop <pipeline_name> [build]")
+			return
+		}
+		pipelineName, number := parsePipelineAndBuild(parts[1:])
+		pipelineName = bot.qualifyJob(pipelineName, message.GuildID, message.ChannelID)
+		if err := bot.authorize(messageActor(message), ActionAbort, pipelineName); err != nil {
+			session.ChannelMessageSend(message.ChannelID, err.Error())
+			return
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP request failed with status: %s", resp.Status)
-	}
+		content, components, err := bot.stopConfirmation(pipelineName, number)
+		if err != nil {
+			session.ChannelMessageSend(message.ChannelID, err.Error())
+			return
+		}
+		session.ChannelMessageSendComplex(message.ChannelID, &discordgo.MessageSend{
+			Content:    content,
+			Components: components,
+		})
+	case strings.HasPrefix(message.Content, "!queue"):
+		items, err := bot.fetchQueue("")
+		if err != nil {
+			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Error fetching the build queue: %v", err))
+			return
+		}
+		session.ChannelMessageSendEmbed(message.ChannelID, bot.queueEmbed(items))
+	case strings.HasPrefix(message.Content, "!cancel"):
+		parts := strings.Fields(message.Content)
+		if len(parts) < 2 {
+			session.ChannelMessageSend(message.ChannelID, "Usage: !cancel <pipeline_name|queue_id>")
+			return
+		}
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+		cancelled, err := bot.cancelQueued(messageActor(message), strings.Join(parts[1:], " "))
+		if err != nil {
+			cancelled = strings.TrimSpace(cancelled + "\n" + err.Error())
+		}
+		session.ChannelMessageSend(message.ChannelID, cancelled)
+	case strings.HasPrefix(message.Content, "!audit"):
+		query, err := parseAuditArgs(strings.Fields(message.Content)[1:])
+		if err != nil {
+			session.ChannelMessageSend(message.ChannelID, fmt.Sprintf("%v\nUsage: !audit [job] [user] [since]", err))
+			return
+		}
+		if query.Job != "" {
+			query.Job = bot.qualifyJob(query.Job, message.GuildID, message.ChannelID)
+		}
 
-	// Unmarshal the JSON data
-	var data map[string]interface{}
-	err = json.Unmarshal(body, &data)
-	if err != nil {
-		return nil, err
+		embed, err := bot.auditMessage(messageActor(message), query)
+		if err != nil {
+			session.ChannelMessageSend(message.ChannelID, err.Error())
+			return
+		}
+		session.ChannelMessageSendEmbed(message.ChannelID, embed)
+	case strings.HasPrefix(message.Content, "!help"):
+		// Provide help information for each command
+		helpMsg := "Available Commands:\n" +
+			"!list ---------------------------> Fetches and displays the Jenkins job list\n" +
+			"    filters: name:<glob|/regex/> status:<failing|running|waiting-input|never-built> folder:<path> view:<name> sort:<name|recent> instance:<name>\n" +
+			"!run <pipeline_name> ---------> Triggers a Jenkins pipeline with the specified name\n" +
+			"!proceed <pipeline_name> [build [input_id]] -> Proceeds the current stage of a pipeline\n" +
+			"    parameters the input asks for go on the following lines as: parameterKey parameterValue\n" +
+			"!abort <pipeline_name> [build [input_id]] ---> Aborts the current stage of a pipeline\n" +
+			"!parameters <pipeline_name> [build] -> Fetches the parameters from the previous build\n" +
+			"!schema <pipeline_name> -----> Shows the parameters a pipeline takes with defaults\n" +
+			"!logs <pipeline_name> [build] -> Streams the console log of a build into a thread\n" +
+			"!stages <pipeline_name> [build] -> Shows the stages of a build\n" +
+			"!stop <pipeline_name> [build] -> Stops a running build after asking for confirmation\n" +
+			"!queue -------------------------> Lists the builds waiting in the Jenkins queue\n" +
+			"!cancel <pipeline_name|queue_id> -> Removes queued builds of a pipeline, or one queue item\n" +
+			"!audit [job] [user] [since] ---> Shows who ran, proceeded and aborted what, e.g. !audit deploy-* @user 7d\n" +
+			"Pipelines in folders are named by their path, e.g. team/service/main\n" +
+			"Prefix a pipeline with an instance name to use another Jenkins, e.g. lab:my-job\n\n" +
+			"!runparams\n<pipeline_name\n\nparameterKey parameterValue1\n\nparameterKey2 Parameter value 2"
+		session.ChannelMessageSend(message.ChannelID, helpMsg)
 	}
 
-	// Extract job names
-	jobs, ok := data["jobs"].([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected format for 'jobs'")
-	}
+}
 
+// fetchJenkinsJobs retrieves the full names of all Jenkins jobs, including the
+// ones inside folders, on every instance. Instances that can't be reached are
+// logged and skipped, so it only fails when none of them answers.
+func (bot *Bot) fetchJenkinsJobs() ([]string, error) {
 	var jobList []string
-
-	for _, job := range jobs {
-		jobMap, ok := job.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("unexpected format for 'job'")
+	var failed []string
+	var lastErr error
+	for _, instance := range bot.instanceNames {
+		jobs, err := bot.jobTree(instance)
+		if err != nil {
+			Logger.Printf("Error fetching jobs of Jenkins instance %s: %v\n", instance, err)
+			failed = append(failed, instance)
+			lastErr = err
+			continue
 		}
 
-		name, nameOk := jobMap["name"].(string)
-
-		if nameOk {
-			jobList = append(jobList, fmt.Sprintf("%s", name))
+		var walk func([]jenkins.Job)
+		walk = func(jobs []jenkins.Job) {
+			for _, job := range jobs {
+				if job.IsFolder() {
+					walk(job.Jobs)
+				} else {
+					jobList = append(jobList, bot.qualifiedName(instance, jobFullName(job)))
+				}
+			}
 		}
+		walk(jobs)
 	}
 
+	if len(bot.instanceNames) > 0 && len(failed) == len(bot.instanceNames) {
+		return nil, fmt.Errorf("no Jenkins instance answered, last error: %w", lastErr)
+	}
 	return jobList, nil
 }
 
-// fetchJenkinsJobStatus retrieves the status and ID of a specific Jenkins job.
-func (bot *Bot) fetchJenkinsJobStatus(jobName string) (string, error) {
-	url := fmt.Sprintf("%s/job/%s/lastBuild/api/json", JenkinsURL, jobName)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
-	}
-
-	// Set Jenkins authorization header
-	authHeader := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte("jenkins:"+JenkinsToken)))
-	req.Header.Set("Authorization", authHeader)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "<:jenkinsnotrun:1254459002167885988>", fmt.Errorf("HTTP request failed with status: %s", resp.Status)
-	}
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+// jobFullName returns the folder qualified name of a job, as used in commands.
+func jobFullName(job jenkins.Job) string {
+	if job.FullName != "" {
+		return job.FullName
 	}
+	return job.Name
+}
 
-	// Unmarshal the JSON data
-	var data map[string]interface{}
-	err = json.Unmarshal(body, &data)
-	if err != nil {
-		return "", err
+// statusEmoji maps the state of a Jenkins build to a Discord emoji. A nil
+// build is a job that never ran.
+func statusEmoji(build *jenkins.Build) string {
+	if build == nil {
+		return "<:jenkinsnotrun:1254459002167885988>"
 	}
 
 	// Check if the job is in progress
-	inProgress, ok := data["inProgress"].(bool)
-	if ok && inProgress {
-		return "<a:jenkinsrunning:1194478025975279687>", nil
-	}
-
-	// If not in progress, return the result
-	status, ok := data["result"].(string)
-	if !ok {
-		return "<:jenkinsnotrun:1254459002167885988>", nil
+	if build.Running() {
+		return "<a:jenkinsrunning:1194478025975279687>"
 	}
 
 	// Map Jenkins statuses to Discord emojis
-	switch status {
+	switch build.Result {
 	case "SUCCESS":
-		return "<:jenkinsgreencheck:1192251531811094588>", nil
+		return "<:jenkinsgreencheck:1192251531811094588>"
 	case "FAILURE":
-		return "<:jenkinsfail:1192276960399851641>", nil
+		return "<:jenkinsfail:1192276960399851641>"
 	default:
-		return "<:jenkinsnotrun:1254459002167885988>", nil
+		return "<:jenkinsnotrun:1254459002167885988>"
 	}
 }
 
-// fetchJenkinsJobRunNumber retrieves the ID of a specific Jenkins job.
-func (bot *Bot) fetchJenkinsJobRunNumber(jobName string) (int, error) {
-	url := fmt.Sprintf("%s/job/%s/lastBuild/api/json", JenkinsURL, jobName)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	// Set Jenkins authorization header
-	authHeader := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte("jenkins:"+JenkinsToken)))
-	req.Header.Set("Authorization", authHeader)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("HTTP request failed with status: %s", resp.Status)
-	}
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-
-	// Log the raw JSON for debugging
-	Logger.Println("Raw Jenkins Jobs JSON:", string(body))
+// triggerJenkinsPipeline triggers a Jenkins pipeline with optional parameters
+// on behalf of who and returns the ID of the resulting queue item.
+func (bot *Bot) triggerJenkinsPipeline(who actor, pipelineName string) (queueID int64, err error) {
+	defer func() {
+		bot.audit(who, auditEntry{Action: ActionRun, Job: pipelineName, QueueID: queueID}, err)
+	}()
 
-	// Unmarshal the JSON data
-	var data map[string]interface{}
-	err = json.Unmarshal(body, &data)
+	client, job, err := bot.jenkinsJobAs(who, ActionRun, pipelineName)
 	if err != nil {
 		return 0, err
 	}
 
-	idStr, idOk := data["id"].(string)
-	if !idOk {
-		return 0, fmt.Errorf("unable to extract build ID")
-	}
-
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		return 0, err
+	// Jobs declaring the trigger parameter need it passed along
+	if bot.Config.TriggerParameter != "" {
+		definitions, err := client.ParameterDefinitions(job)
+		values := make(map[string]string)
+		if err == nil && bot.addTriggerParameter(who, definitions, values) {
+			return client.TriggerWithParameters(job, values)
+		}
 	}
 
-	return int(id), nil
-}
-
-// triggerJenkinsPipeline triggers a Jenkins pipeline with optional parameters.
-func (bot *Bot) triggerJenkinsPipeline(pipelineName string) error {
 	// Attempt to trigger pipeline without parameters
-	urlWithoutParams := fmt.Sprintf("%s/job/%s/build", JenkinsURL, pipelineName)
-	err := bot.triggerPipelineWithURL(urlWithoutParams)
+	queueID, err = client.Trigger(job)
 
 	if err != nil {
 		// If triggering without parameters fails, try triggering with parameters
-		urlWithParams := fmt.Sprintf("%s/job/%s/buildWithParameters", JenkinsURL, pipelineName)
-		err = bot.triggerPipelineWithURL(urlWithParams)
+		queueID, err = client.TriggerWithParameters(job, nil)
 	}
 
-	return err
+	return queueID, err
 }
 
-// triggerPipelineWithURL triggers a Jenkins pipeline with the given URL.
-func (bot *Bot) triggerPipelineWithURL(url string) error {
-	req, err := http.NewRequest("POST", url, nil)
+// announceTrigger tells the channel who triggered a pipeline and starts
+// tracking the build so the message can be updated with its result.
+func (bot *Bot) announceTrigger(who actor, pipelineName string, queueID int64) {
+	msg, err := bot.Session.ChannelMessageSend(who.ChannelID, fmt.Sprintf("Jenkins pipeline '%s' triggered successfully!", pipelineName))
 	if err != nil {
-		return err
-	}
-
-	// Set Jenkins authorization header
-	authHeader := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte("jenkins:"+JenkinsToken)))
-	req.Header.Set("Authorization", authHeader)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("HTTP request failed with status: %s", resp.Status)
+		Logger.Println("Error announcing trigger:", err)
+		return
 	}
 
-	return nil
+	go bot.trackBuild(who, msg.ID, pipelineName, queueID)
 }
 
-func (bot *Bot) proceedJenkinsPipeline(pipelineName string) error {
-	// Fetch the most recent build status and ID
-	jobName := strings.ReplaceAll(pipelineName, " ", "%20")
-	jobId, err := bot.fetchJenkinsJobRunNumber(jobName)
-	inputIdentifier, err := bot.fetchJenkinsInputIdentifier(jobName, jobId)
-	if err != nil {
-		return err
-	}
-
-	// Construct the URL to proceed the Jenkins pipeline
-	url := fmt.Sprintf("%s/job/%s/%d/input/%s/proceedEmpty", JenkinsURL, jobName, jobId, inputIdentifier)
-	Logger.Println("Proceed URL: ", url)
-
-	// Perform the HTTP request
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		return err
-	}
-
-	// Set Jenkins authorization header
-	authHeader := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte("jenkins:"+JenkinsToken)))
-	req.Header.Set("Authorization", authHeader)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	// Check the response status
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("HTTP request failed with status: %s", resp.Status)
-	}
-
-	return nil
+// proceedJenkinsPipeline approves the input step of a pipeline selected by
+// number and inputID with parameters, see answerJenkinsInput.
+func (bot *Bot) proceedJenkinsPipeline(who actor, pipelineName string, number int, inputID string, parameters map[string]string) ([]pendingInput, error) {
+	return bot.answerJenkinsInput(who, ActionProceed, pipelineName, number, inputID, parameters)
 }
 
-func (bot *Bot) abortJenkinsPipeline(pipelineName string) error {
-	// Fetch the most recent build status and ID
-	jobName := strings.ReplaceAll(pipelineName, " ", "%20")
-	jobId, err := bot.fetchJenkinsJobRunNumber(jobName)
-	inputIdentifier, err := bot.fetchJenkinsInputIdentifier(jobName, jobId)
-	if err != nil {
-		return err
-	}
-
-	// Construct the URL to abort the Jenkins pipeline
-	url := fmt.Sprintf("%s/job/%s/%d/input/%s/abort", JenkinsURL, jobName, jobId, inputIdentifier)
-	Logger.Println("Proceed URL: ", url)
+// abortJenkinsPipeline rejects the input step of a pipeline selected by
+// number and inputID, see answerJenkinsInput.
+func (bot *Bot) abortJenkinsPipeline(who actor, pipelineName string, number int, inputID string) ([]pendingInput, error) {
+	return bot.answerJenkinsInput(who, ActionAbort, pipelineName, number, inputID, nil)
+}
 
-	// Perform the HTTP request
-	req, err := http.NewRequest("POST", url, nil)
+// answerJenkinsInput proceeds or aborts the one input step number and inputID
+// select, where 0 and "" leave the build and input open. When more than one
+// input step qualifies nothing is answered and they are returned for the user
+// to pick from. An input step asking for parameters is proceeded with
+// parameters, and returned on its own when they are nil so the user can be
+// asked for them.
+func (bot *Bot) answerJenkinsInput(who actor, action, pipelineName string, number int, inputID string, parameters map[string]string) ([]pendingInput, error) {
+	inputs, err := bot.findPendingInputs(pipelineName, number, inputID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Set Jenkins authorization header
-	authHeader := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte("jenkins:"+JenkinsToken)))
-	req.Header.Set("Authorization", authHeader)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+	if len(inputs) > 1 {
+		return inputs, nil
 	}
-	defer resp.Body.Close()
 
-	// Check the response status
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("HTTP request failed with status: %s", resp.Status)
+	input := inputs[0]
+	ref := inputRef{Job: pipelineName, Build: input.Build, InputID: input.ID}
+	if action == ActionProceed && (len(input.Inputs) > 0 || parameters != nil) {
+		if parameters == nil {
+			return inputs, nil
+		}
+		_, err := bot.proceedInputWithParameters(who, ref, input.PendingInput, parameters)
+		return nil, err
 	}
-
-	return nil
+	return nil, bot.answerInput(who, action, ref, input.PendingInput)
 }
 
-// fetchJenkinsInputIdentifier retrieves the input identifier for a specific Jenkins job run.
-func (bot *Bot) fetchJenkinsInputIdentifier(pipelineName string, runNumber int) (string, error) {
-	// Construct the URL to fetch the input identifier
-	jobName := strings.ReplaceAll(pipelineName, " ", "%20")
-	url := fmt.Sprintf("%s/job/%s/%d/wfapi/pendingInputActions", JenkinsURL, jobName, runNumber)
-
-	req, err := http.NewRequest("GET", url, nil)
+// fetchJenkinsJobParameters formats the parameters of a build of a pipeline,
+// the last one when number is 0.
+func (bot *Bot) fetchJenkinsJobParameters(pipelineName string, number int) (string, error) {
+	client, job, err := bot.jenkinsJob(pipelineName)
 	if err != nil {
 		return "", err
 	}
 
-	// Set Jenkins authorization header
-	authHeader := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte("jenkins:"+JenkinsToken)))
-	req.Header.Set("Authorization", authHeader)
-
-	resp, err := http.DefaultClient.Do(req)
+	number, err = bot.resolveBuildNumber(pipelineName, number)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP request failed to fetch Input Identifier with: %s", resp.Status)
-	}
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
+	build, err := client.Build(job, number)
 	if err != nil {
 		return "", err
 	}
 
-	Logger.Println("Raw Jenkins Jobs JSON:", string(body))
-
-	// Unmarshal the JSON data
-	var actions []map[string]interface{}
-	err = json.Unmarshal(body, &actions)
-	if err != nil {
-		return "", err
-	}
-
-	// Check if there are any actions
-	if len(actions) > 0 {
-		// Extract the first action
-		action := actions[0]
-
-		// Check if the action has the 'id' field
-		if id, idOk := action["id"].(string); idOk {
-			return id, nil
+	var buffer strings.Builder
+	for _, parameter := range build.Parameters() {
+		if parameter.Value == nil {
+			continue
 		}
+		buffer.WriteString(fmt.Sprintf("\n\n**%s:** \n%v", parameter.Name, parameter.Value))
 	}
 
-	return "", fmt.Errorf("unable to extract input identifier")
+	return buffer.String(), nil
 }
 
-func (bot *Bot) fetchJenkinsJobParameters(pipelineName string) (string, error) {
-	// Fetch the run number for the given pipeline
-	jobName := strings.ReplaceAll(pipelineName, " ", "%20")
-	runNumber, err := bot.fetchJenkinsJobRunNumber(jobName)
-	if err != nil {
-		return "", err
-	}
-
-	// Construct the URL to fetch Jenkins job parameters
-	url := fmt.Sprintf("%s/job/%s/api/json?tree=builds[actions[parameters[name,value]],number]", JenkinsURL, jobName)
+func (bot *Bot) runPipelineWithParameters(who actor, message string) (string, int64, error) {
+	// Split the message into lines
+	lines := strings.Split(message, "\n")
 
-	// Perform the HTTP request
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
+	// Ensure the message has at least three lines (command, pipeline name, and parameters)
+	if len(lines) < 3 {
+		return "", 0, fmt.Errorf("invalid message format")
 	}
 
-	// Set Jenkins authorization header
-	authHeader := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte("jenkins:"+JenkinsToken)))
-	req.Header.Set("Authorization", authHeader)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	// Check the response status
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP request failed with status: %s", resp.Status)
+	// Extract pipeline name from the second line
+	pipelineName := bot.qualifyJob(strings.TrimSpace(lines[1]), who.GuildID, who.ChannelID)
+	if err := bot.authorize(who, ActionRunParams, pipelineName); err != nil {
+		return "", 0, err
 	}
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
+	// Extract parameters from the remaining lines
+	parameters, err := parseParameterLines(lines[2:])
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
-	// Unmarshal the JSON data
-	var data map[string]interface{}
-	err = json.Unmarshal(body, &data)
+	queueID, err := bot.triggerJenkinsPipelineParams(who, pipelineName, parameters)
 	if err != nil {
-		return "", err
-	}
-
-	// Extract builds information
-	builds, ok := data["builds"].([]interface{})
-	if !ok {
-		return "", fmt.Errorf("unexpected format for 'builds'")
-	}
-
-	// Iterate over builds and find the one with the matching runNumber
-	for _, build := range builds {
-		buildMap, ok := build.(map[string]interface{})
-		if !ok {
-			return "", fmt.Errorf("unexpected format for 'build'")
-		}
-
-		// Extract build number
-		buildNumber, numberOk := buildMap["number"].(float64)
-		if !numberOk {
-			return "", fmt.Errorf("unable to extract build number")
-		}
-
-		// Check if the build number matches the runNumber
-		if int(buildNumber) == runNumber {
-			// Extract actions
-			actions, actionsOk := buildMap["actions"].([]interface{})
-			if !actionsOk {
-				continue // No actions in this build
-			}
-
-			// Initialize a buffer to accumulate parameter data
-			var buffer strings.Builder
-
-			// Iterate over actions and accumulate parameter data
-			for _, action := range actions {
-				actionMap, ok := action.(map[string]interface{})
-				if !ok {
-					continue // Skip if not a valid action
-				}
-
-				// Extract parameters
-				parameters, parametersOk := actionMap["parameters"].([]interface{})
-				if !parametersOk {
-					continue // No parameters in this action
-				}
-
-				// Accumulate parameter data
-				for _, parameter := range parameters {
-					parameterMap, ok := parameter.(map[string]interface{})
-					if !ok {
-						continue // Skip if not a valid parameter
-					}
-
-					name, nameOk := parameterMap["name"].(string)
-					value, valueOk := parameterMap["value"].(string)
-
-					if nameOk && valueOk {
-						// Add parameter data to the buffer
-						buffer.WriteString(fmt.Sprintf("\n\n**%s:** \n%s", name, value))
-					}
-				}
-			}
-
-			return buffer.String(), nil
-		}
+		return "", 0, fmt.Errorf("failed to trigger Jenkins pipeline: %v", err)
 	}
 
-	// If the build with the matching runNumber is not found
-	return "", fmt.Errorf("build with runNumber %d not found", runNumber)
+	return pipelineName, queueID, nil
 }
 
-func (bot *Bot) runPipelineWithParameters(message string) (string, error) {
-	// Split the message into lines
-	lines := strings.Split(message, "\n")
-
-	// Ensure the message has at least three lines (command, pipeline name, and parameters)
-	if len(lines) < 3 {
-		return "", fmt.Errorf("invalid message format")
-	}
-
-	// Extract pipeline name from the second line
-	pipelineName := strings.TrimSpace(lines[1])
+// parseParameterLines parses "key value" lines into a parameter map. Blank
+// lines are skipped and a key given on several lines gets its values joined
+// with spaces. It returns nil when there are no parameters.
+func parseParameterLines(lines []string) (map[string]string, error) {
+	var parameters map[string]string
 
-	// Extract parameters from the remaining lines
-	parameters := make(map[string]string)
-
-	for _, line := range lines[2:] {
+	for _, line := range lines {
 		// Trim leading and trailing whitespaces
 		line = strings.TrimSpace(line)
 
@@ -732,13 +715,16 @@ func (bot *Bot) runPipelineWithParameters(message string) (string, error) {
 		// Split the line into key and values
 		parts := strings.SplitN(line, " ", 2)
 		if len(parts) < 2 {
-			return "", fmt.Errorf("invalid parameter format")
+			return nil, fmt.Errorf("invalid parameter format")
 		}
 
 		key := parts[0]
 		value := parts[1]
 
 		// Append the value to the existing values (if any)
+		if parameters == nil {
+			parameters = make(map[string]string)
+		}
 		existingValue, found := parameters[key]
 		if found {
 			parameters[key] = existingValue + " " + value
@@ -747,78 +733,53 @@ func (bot *Bot) runPipelineWithParameters(message string) (string, error) {
 		}
 	}
 
-	err := bot.triggerJenkinsPipelineParams(pipelineName, parameters)
-	if err != nil {
-		return "", fmt.Errorf("failed to trigger Jenkins pipeline: %v", err)
-	}
-
-	return pipelineName, nil
+	return parameters, nil
 }
 
-// triggerPipelineWithParameters triggers a Jenkins pipeline with the given parameters.
-func (bot *Bot) triggerJenkinsPipelineParams(jobName string, inputJson map[string]string) error {
-	// Convert inputJson to an array of objects
-	var jsonArray []map[string]string
-	for key, value := range inputJson {
-		jsonArray = append(jsonArray, map[string]string{key: value})
-	}
+// triggerJenkinsPipelineParams triggers a Jenkins pipeline with the given parameters
+// on behalf of who and returns the ID of the resulting queue item.
+// The parameters are validated against the job's definitions first.
+func (bot *Bot) triggerJenkinsPipelineParams(who actor, jobName string, parameters map[string]string) (queueID int64, err error) {
+	entry := auditEntry{Action: ActionRunParams, Job: jobName}
+	defer func() {
+		entry.QueueID = queueID
+		bot.audit(who, entry, err)
+	}()
 
-	// Convert the array to a JSON string
-	jsonParams, err := json.Marshal(jsonArray)
+	client, job, err := bot.jenkinsJobAs(who, ActionRunParams, jobName)
 	if err != nil {
-		return fmt.Errorf("error encoding JSON: %w", err)
+		return 0, err
 	}
 
-	// Convert byte slice to string for better logging
-	Logger.Println("json Params: ", string(jsonParams))
-
-	var parameters []map[string]string
-	err = json.Unmarshal(jsonParams, &parameters)
+	definitions, err := client.ParameterDefinitions(job)
 	if err != nil {
-		return fmt.Errorf("error decoding JSON: %w", err)
-	}
-
-	var queryParams []string
-	for _, param := range parameters {
-		for key, value := range param {
-			queryParams = append(queryParams, fmt.Sprintf("%s=%s", key, url.QueryEscape(value)))
-		}
+		return 0, fmt.Errorf("failed to fetch parameter definitions: %w", err)
 	}
 
-	finalURL := fmt.Sprintf("%s/job/%s/buildWithParameters?%s", JenkinsURL, jobName, strings.Join(queryParams, "&"))
-
-	Logger.Println("Final URL: ", finalURL)
-
-	req, err := http.NewRequest("POST", finalURL, nil)
+	values, err := validateParameters(jobName, bot.visibleParameters(definitions), parameters)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	bot.addTriggerParameter(who, definitions, values)
 
-	// Set Jenkins authorization header and content type.
-	authHeader := fmt.Sprintf("Basic %s", base64.StdEncoding.EncodeToString([]byte("jenkins:"+JenkinsToken)))
-	req.Header.Set("Authorization", authHeader)
+	entry.Parameters = maskParameters(definitions, values)
+	Logger.Printf("Triggering %s with parameters: %v\n", jobName, entry.Parameters)
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	// Log the response status code
-	Logger.Printf("Jenkins API response status code: %s\n", resp.Status)
+	return client.TriggerWithParameters(job, values)
+}
 
-	// Log the response body
-	responseBody, err := io.ReadAll(resp.Body)
+// fetchJenkinsParameterSchema describes the parameters a pipeline declares.
+func (bot *Bot) fetchJenkinsParameterSchema(pipelineName string) (string, error) {
+	client, job, err := bot.jenkinsJob(pipelineName)
 	if err != nil {
-		return fmt.Errorf("error reading response body: %v", err)
+		return "", err
 	}
-	Logger.Printf("Jenkins API response body: %s\n", responseBody)
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("HTTP request failed with status: %s", resp.Status)
+	definitions, err := client.ParameterDefinitions(job)
+	if err != nil {
+		return "", err
 	}
-
-	return nil
+	return formatParameterSchema(bot.visibleParameters(definitions)), nil
 }
 
 func getGIFURL(searchTerm string, limit int) (string, error) {
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"bot/jenkins"
+)
+
+func TestFetchJenkinsJobsSkipsFailingInstances(t *testing.T) {
+	Logger = log.New(io.Discard, "", 0)
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jobs": [{"name": "deploy", "fullName": "deploy"}, {"_class": "com.cloudbees.hudson.plugins.folder.Folder", "name": "team", "fullName": "team", "jobs": [{"name": "build", "fullName": "team/build"}]}]}`))
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	bot := &Bot{
+		Config: &Config{DefaultJenkins: "main"},
+		instances: map[string]*jenkins.Client{
+			"main": jenkins.NewClient(healthy.URL, nil),
+			"lab":  jenkins.NewClient(broken.URL, nil),
+		},
+		instanceNames: []string{"lab", "main"},
+	}
+
+	names, err := bot.fetchJenkinsJobs()
+	if err != nil {
+		t.Fatalf("fetchJenkinsJobs() with one healthy instance: %v", err)
+	}
+	if want := []string{"main:deploy", "main:team/build"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("fetchJenkinsJobs() = %q, want %q", names, want)
+	}
+
+	bot.instances["main"] = jenkins.NewClient(broken.URL, nil)
+	bot.tree.entries = nil
+	if _, err := bot.fetchJenkinsJobs(); err == nil {
+		t.Error("fetchJenkinsJobs() succeeded with every instance failing")
+	}
+}
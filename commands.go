@@ -0,0 +1,592 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// pipelineOption is the job name option shared by the Jenkins commands.
+var pipelineOption = &discordgo.ApplicationCommandOption{
+	Type:         discordgo.ApplicationCommandOptionString,
+	Name:         "pipeline",
+	Description:  "Name of the Jenkins pipeline",
+	Required:     true,
+	Autocomplete: true,
+}
+
+// buildOption selects a build by number, defaulting to the last build.
+var buildOption = &discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionInteger,
+	Name:        "build",
+	Description: "Build number, defaults to the last build",
+	MinValue:    &minBuildNumber,
+}
+
+var minBuildNumber = 1.0
+
+// inputOption selects an input step by ID when a build waits on several.
+var inputOption = &discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionString,
+	Name:        "input",
+	Description: "ID of the input step, asks which one when left out and there are several",
+}
+
+// slashCommands are registered with Discord when the bot connects.
+var slashCommands = []*discordgo.ApplicationCommand{
+	{
+		Name:        "list",
+		Description: "Fetches and displays the Jenkins job list",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "name",
+				Description: "Only jobs matching a glob, or a regular expression written as /expr/",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "status",
+				Description: "Only jobs in this state",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "failing", Value: StatusFailing},
+					{Name: "running", Value: StatusRunning},
+					{Name: "waiting for input", Value: StatusWaitingInput},
+					{Name: "never built", Value: StatusNeverBuilt},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "folder",
+				Description: "Only jobs inside this folder",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "view",
+				Description: "Only jobs in this Jenkins view",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "instance",
+				Description: "Jenkins instance to list, defaults to the one of this channel",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "sort",
+				Description: "Order of the list",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "name", Value: "name"},
+					{Name: "last build time", Value: SortRecent},
+				},
+			},
+		},
+	},
+	{
+		Name:        "run",
+		Description: "Triggers a Jenkins pipeline",
+		Options:     []*discordgo.ApplicationCommandOption{pipelineOption},
+	},
+	{
+		Name:        "runparams",
+		Description: "Triggers a Jenkins pipeline with parameters",
+		Options: []*discordgo.ApplicationCommandOption{
+			pipelineOption,
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "parameters",
+				Description: "Parameters as key=value pairs separated by ';', leave out to fill in a form",
+				Required:    false,
+			},
+		},
+	},
+	{
+		Name:        "proceed",
+		Description: "Proceeds the current stage of a pipeline",
+		Options: []*discordgo.ApplicationCommandOption{
+			pipelineOption,
+			buildOption,
+			inputOption,
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "parameters",
+				Description: "Parameters of the input as key=value pairs separated by ';', asked for when left out",
+			},
+		},
+	},
+	{
+		Name:        "abort",
+		Description: "Aborts the current stage of a pipeline",
+		Options: []*discordgo.ApplicationCommandOption{
+			pipelineOption,
+			buildOption,
+			inputOption,
+		},
+	},
+	{
+		Name:        "parameters",
+		Description: "Fetches the parameters from the previous build",
+		Options: []*discordgo.ApplicationCommandOption{
+			pipelineOption,
+			buildOption,
+		},
+	},
+	{
+		Name:        "schema",
+		Description: "Shows the parameters a pipeline takes with their defaults",
+		Options:     []*discordgo.ApplicationCommandOption{pipelineOption},
+	},
+	{
+		Name:        "logs",
+		Description: "Streams the console log of a build into a thread",
+		Options: []*discordgo.ApplicationCommandOption{
+			pipelineOption,
+			buildOption,
+		},
+	},
+	{
+		Name:        "stages",
+		Description: "Shows the stages of a build, updating while it runs",
+		Options: []*discordgo.ApplicationCommandOption{
+			pipelineOption,
+			buildOption,
+		},
+	},
+	{
+		Name:        "stop",
+		Description: "Stops a running build, terminating it if it doesn't stop in time",
+		Options: []*discordgo.ApplicationCommandOption{
+			pipelineOption,
+			buildOption,
+		},
+	},
+	{
+		Name:        "queue",
+		Description: "Lists the builds waiting in the Jenkins queue",
+	},
+	{
+		Name:        "cancel",
+		Description: "Removes builds from the Jenkins queue",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "target",
+				Description: "Queue item ID, or a pipeline to cancel all its queued builds",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "audit",
+		Description: "Shows who ran, proceeded and aborted which jobs through the bot",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "job",
+				Description: "Only actions on jobs matching a glob",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Only actions of this user",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "since",
+				Description: "How far back to look, e.g. 24h, 7d or 2024-01-31",
+			},
+		},
+	},
+	{
+		Name:        "gif",
+		Description: "Posts a GIF for a search term",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "term",
+				Description: "What to search Giphy for",
+				Required:    true,
+			},
+		},
+	},
+}
+
+// commandActions maps the slash commands that act on a pipeline to the
+// permission they require.
+var commandActions = map[string]string{
+	"run":       ActionRun,
+	"runparams": ActionRunParams,
+	"proceed":   ActionProceed,
+	"abort":     ActionAbort,
+	"stop":      ActionAbort,
+}
+
+// commandHandlers maps slash command names to their handlers.
+var commandHandlers = map[string]func(*Bot, *discordgo.Session, *discordgo.InteractionCreate){
+	"list":       (*Bot).handleListCommand,
+	"run":        (*Bot).handleRunCommand,
+	"runparams":  (*Bot).handleRunParamsCommand,
+	"proceed":    (*Bot).handleProceedCommand,
+	"abort":      (*Bot).handleAbortCommand,
+	"parameters": (*Bot).handleParametersCommand,
+	"schema":     (*Bot).handleSchemaCommand,
+	"logs":       (*Bot).handleLogsCommand,
+	"stages":     (*Bot).handleStagesCommand,
+	"gif":        (*Bot).handleGifCommand,
+	"link":       (*Bot).handleLinkCommand,
+	"unlink":     (*Bot).handleUnlinkCommand,
+	"audit":      (*Bot).handleAuditCommand,
+	"stop":       (*Bot).handleStopCommand,
+	"queue":      (*Bot).handleQueueCommand,
+	"cancel":     (*Bot).handleCancelCommand,
+}
+
+// ready registers the slash commands once the session is established. When
+// GuildID is set the commands are registered for that guild only, which makes
+// changes show up immediately instead of after Discord's global cache expires.
+// The link commands are always global as guild commands can't be used in DMs.
+func (bot *Bot) ready(session *discordgo.Session, event *discordgo.Ready) {
+	commands := slashCommands
+	if bot.GuildID == "" {
+		commands = append(append([]*discordgo.ApplicationCommand{}, slashCommands...), linkCommands...)
+	} else {
+		if _, err := session.ApplicationCommandBulkOverwrite(event.User.ID, "", linkCommands); err != nil {
+			Logger.Println("Error registering link commands:", err)
+		}
+	}
+
+	_, err := session.ApplicationCommandBulkOverwrite(event.User.ID, bot.GuildID, commands)
+	if err != nil {
+		Logger.Println("Error registering slash commands:", err)
+		return
+	}
+	Logger.Printf("Registered %d slash commands\n", len(commands))
+}
+
+// interactionCreate dispatches slash command interactions to their handlers.
+func (bot *Bot) interactionCreate(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	switch interaction.Type {
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		bot.autocompletePipeline(session, interaction)
+	case discordgo.InteractionApplicationCommand:
+		name := interaction.ApplicationCommandData().Name
+		handler, ok := commandHandlers[name]
+		if !ok {
+			return
+		}
+		if action, ok := commandActions[name]; ok {
+			pipelineName := bot.pipelineOption(interaction)
+			if err := bot.authorize(interactionActor(interaction), action, pipelineName); err != nil {
+				bot.respondEphemeral(session, interaction, err.Error())
+				return
+			}
+		}
+		handler(bot, session, interaction)
+	case discordgo.InteractionMessageComponent:
+		customID := interaction.MessageComponentData().CustomID
+		switch {
+		case strings.HasPrefix(customID, inputButtonPrefix+"|"):
+			bot.handleInputButton(session, interaction)
+		case strings.HasPrefix(customID, stopButtonPrefix+"|"):
+			bot.handleStopButton(session, interaction)
+		case strings.HasPrefix(customID, paramFormPrefix+"|"):
+			bot.handleParamFormComponent(session, interaction)
+		case strings.HasPrefix(customID, jobListPrefix+"|"):
+			bot.handleJobListPage(session, interaction)
+		}
+	case discordgo.InteractionModalSubmit:
+		customID := interaction.ModalSubmitData().CustomID
+		switch {
+		case strings.HasPrefix(customID, inputButtonPrefix+"|"):
+			bot.handleInputModal(session, interaction)
+		case strings.HasPrefix(customID, paramFormPrefix+"|"):
+			bot.handleParamFormModal(session, interaction)
+		}
+	}
+}
+
+// commandOptions returns the options of a slash command keyed by name.
+func commandOptions(interaction *discordgo.InteractionCreate) map[string]*discordgo.ApplicationCommandInteractionDataOption {
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, option := range interaction.ApplicationCommandData().Options {
+		options[option.Name] = option
+	}
+	return options
+}
+
+// pipelineOption returns the pipeline option of a slash command, qualified
+// with the Jenkins instance of the channel when it doesn't name one.
+func (bot *Bot) pipelineOption(interaction *discordgo.InteractionCreate) string {
+	return bot.qualifyJob(commandOptions(interaction)["pipeline"].StringValue(), interaction.GuildID, interaction.ChannelID)
+}
+
+// deferResponse acknowledges an interaction so the handler can take longer
+// than Discord's three second window to produce the actual reply.
+func (bot *Bot) deferResponse(session *discordgo.Session, interaction *discordgo.InteractionCreate) bool {
+	err := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		Logger.Println("Error deferring interaction response:", err)
+		return false
+	}
+	return true
+}
+
+// editResponse replaces the deferred response of an interaction with content.
+func (bot *Bot) editResponse(session *discordgo.Session, interaction *discordgo.InteractionCreate, content string) *discordgo.Message {
+	msg, err := session.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+	if err != nil {
+		Logger.Println("Error editing interaction response:", err)
+		return nil
+	}
+	return msg
+}
+
+// respondEphemeral replies to an interaction with a message only the invoking
+// user can see.
+func (bot *Bot) respondEphemeral(session *discordgo.Session, interaction *discordgo.InteractionCreate, content string) {
+	err := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		Logger.Println("Error responding to interaction:", err)
+	}
+}
+
+// followupEphemeral sends a message only the invoking user can see after the
+// interaction has already been responded to.
+func (bot *Bot) followupEphemeral(session *discordgo.Session, interaction *discordgo.InteractionCreate, content string) {
+	_, err := session.FollowupMessageCreate(interaction.Interaction, true, &discordgo.WebhookParams{
+		Content: content,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+	if err != nil {
+		Logger.Println("Error sending interaction followup:", err)
+	}
+}
+
+func (bot *Bot) handleListCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	options := commandOptions(interaction)
+	optionValue := func(name string) string {
+		if option, ok := options[name]; ok {
+			return option.StringValue()
+		}
+		return ""
+	}
+	filter := listFilter{
+		Name:     optionValue("name"),
+		Status:   optionValue("status"),
+		Folder:   strings.Trim(optionValue("folder"), "/"),
+		View:     optionValue("view"),
+		Sort:     optionValue("sort"),
+		Instance: optionValue("instance"),
+	}
+	if filter.Instance == "" {
+		filter.Instance = bot.channelInstance(interaction.GuildID, interaction.ChannelID)
+	}
+	if err := filter.validate(); err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	list, err := bot.jobListMessage(filter, 0)
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error fetching Jenkins job list: %v", err))
+		return
+	}
+	_, err = session.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &list.Embeds,
+		Components: &list.Components,
+	})
+	if err != nil {
+		Logger.Println("Error editing interaction response:", err)
+	}
+}
+
+func (bot *Bot) handleRunCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	pipelineName := bot.pipelineOption(interaction)
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	queueID, err := bot.triggerJenkinsPipeline(interactionActor(interaction), pipelineName)
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error triggering Jenkins pipeline '%s': %v", pipelineName, err))
+		return
+	}
+	bot.respondTriggered(session, interaction, pipelineName, queueID)
+}
+
+func (bot *Bot) handleRunParamsCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	options := commandOptions(interaction)
+	pipelineName := bot.pipelineOption(interaction)
+
+	// Without parameters, ask for them in a form. This has to happen before
+	// deferring as a modal can only be the first response to an interaction.
+	if options["parameters"] == nil {
+		bot.startParamForm(session, interaction, pipelineName)
+		return
+	}
+
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	parameters, err := parseParameterList(options["parameters"].StringValue())
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error handling /runparams: %v", err))
+		return
+	}
+
+	queueID, err := bot.triggerJenkinsPipelineParams(interactionActor(interaction), pipelineName, parameters)
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error triggering Jenkins pipeline '%s': %v", pipelineName, err))
+		return
+	}
+	bot.respondTriggered(session, interaction, pipelineName, queueID)
+}
+
+// respondTriggered is the slash command counterpart of announceTrigger.
+func (bot *Bot) respondTriggered(session *discordgo.Session, interaction *discordgo.InteractionCreate, pipelineName string, queueID int64) {
+	msg := bot.editResponse(session, interaction, fmt.Sprintf("Jenkins pipeline '%s' triggered successfully!", pipelineName))
+	if msg == nil {
+		return
+	}
+
+	go bot.trackBuild(interactionActor(interaction), msg.ID, pipelineName, queueID)
+}
+
+func (bot *Bot) handleProceedCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	bot.handleInputCommand(session, interaction, ActionProceed)
+}
+
+func (bot *Bot) handleAbortCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	bot.handleInputCommand(session, interaction, ActionAbort)
+}
+
+// handleInputCommand answers an input step for /proceed and /abort, or asks
+// which one when the options leave more than one, and for the parameters of
+// an input that wants some when they are not given.
+func (bot *Bot) handleInputCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate, action string) {
+	options := commandOptions(interaction)
+	pipelineName := bot.pipelineOption(interaction)
+	number := 0
+	if option, ok := options["build"]; ok {
+		number = int(option.IntValue())
+	}
+	inputID := ""
+	if option, ok := options["input"]; ok {
+		inputID = strings.TrimSpace(option.StringValue())
+	}
+	var parameters map[string]string
+	if option, ok := options["parameters"]; ok {
+		var err error
+		if parameters, err = parseParameterList(option.StringValue()); err != nil {
+			bot.respondEphemeral(session, interaction, fmt.Sprintf("Error handling /%s: %v", action, err))
+			return
+		}
+	}
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	verb, done := "proceeding", "proceeded successfully!"
+	if action == ActionAbort {
+		verb, done = "aborting", "aborted"
+	}
+
+	choices, err := bot.answerJenkinsInput(interactionActor(interaction), action, pipelineName, number, inputID, parameters)
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error %s Jenkins pipeline '%s': %v", verb, pipelineName, err))
+		return
+	}
+	if len(choices) == 0 {
+		bot.editResponse(session, interaction, fmt.Sprintf("Jenkins pipeline '%s' %s", pipelineName, done))
+		return
+	}
+
+	content, components := inputChoices(action, pipelineName, choices)
+	_, err = session.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &components,
+	})
+	if err != nil {
+		Logger.Println("Error editing interaction response:", err)
+	}
+}
+
+func (bot *Bot) handleParametersCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	pipelineName := bot.pipelineOption(interaction)
+	number := 0
+	if option, ok := commandOptions(interaction)["build"]; ok {
+		number = int(option.IntValue())
+	}
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	parameters, err := bot.fetchJenkinsJobParameters(pipelineName, number)
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error fetching parameters for '%s': %v", pipelineName, err))
+		return
+	}
+	bot.editResponse(session, interaction, fmt.Sprintf("Parameters from previous run:%s", parameters))
+}
+
+func (bot *Bot) handleSchemaCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	pipelineName := bot.pipelineOption(interaction)
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	schema, err := bot.fetchJenkinsParameterSchema(pipelineName)
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error fetching parameter definitions for '%s': %v", pipelineName, err))
+		return
+	}
+	bot.editResponse(session, interaction, fmt.Sprintf("Parameters of '%s':%s", pipelineName, schema))
+}
+
+func (bot *Bot) handleGifCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	term := strings.TrimSpace(commandOptions(interaction)["term"].StringValue())
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	gifURL, err := getGIFURL(term, 20)
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Couldn't fetch GIF for '%s': %v", term, err))
+		return
+	}
+	bot.editResponse(session, interaction, gifURL)
+}
+
+// parseParameterList parses "key=value; key2=value 2" into a parameter map.
+// Slash command options are single line, so ';' takes the place of the
+// newlines used by !runparams.
+func parseParameterList(input string) (map[string]string, error) {
+	parameters := make(map[string]string)
+	for _, pair := range strings.Split(input, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid parameter %q, expected key=value", pair)
+		}
+		parameters[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return parameters, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DefaultConfigFile is read when BOT_CONFIG is not set.
+const DefaultConfigFile = "config.json"
+
+// Config holds the settings that don't fit in .env.
+type Config struct {
+	// Permissions restrict who may act on which jobs. When empty every user
+	// may do everything, as before permissions existed.
+	Permissions []PermissionRule `json:"permissions"`
+	// Notifications route build events received by the webhook server to
+	// channels. The first rule matching the job wins.
+	Notifications []NotificationRule `json:"notifications"`
+
+	// Jenkins lists the Jenkins instances by name. When empty, the JENKINS_
+	// variables from .env make up a single instance.
+	Jenkins map[string]JenkinsInstance `json:"jenkins"`
+	// DefaultJenkins is the instance used when a command doesn't name one
+	// and neither its channel nor its guild has a default.
+	DefaultJenkins string `json:"defaultJenkins"`
+	// ChannelJenkins and GuildJenkins map channel and guild IDs to the
+	// instance commands there default to.
+	ChannelJenkins map[string]string `json:"channelJenkins"`
+	GuildJenkins   map[string]string `json:"guildJenkins"`
+
+	// TriggerParameter names a job parameter the bot sets to who triggered
+	// a build from Discord, for jobs that declare it. Users can't set it.
+	TriggerParameter string `json:"triggerParameter"`
+
+	// Stop sets how stopping a build escalates.
+	Stop StopConfig `json:"stop"`
+
+	// AuditLog is the file actions taken through the bot are recorded in,
+	// one JSON object per line.
+	AuditLog string `json:"auditLog"`
+
+	// Identities configures running actions as the user's own Jenkins
+	// account.
+	Identities IdentityConfig `json:"identities"`
+}
+
+// loadConfig reads the JSON config at path. A missing file is not an error and
+// yields an empty config.
+func loadConfig(path string) (*Config, error) {
+	config := &Config{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return config, nil
+}
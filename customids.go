@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Discord caps custom IDs at 100 characters. Buttons whose data doesn't fit
+// keep it in memory instead and carry "#<key>" in its place.
+const (
+	maxCustomIDLength = 100
+	// customIDExpiry is how long such data is kept. Input steps can wait for
+	// a long time, so it is much longer than paramFormExpiry.
+	customIDExpiry = 24 * time.Hour
+)
+
+// customIDValue is data kept for a custom ID.
+type customIDValue struct {
+	Value   interface{}
+	Created time.Time
+}
+
+var (
+	customIDValues      = make(map[string]customIDValue)
+	customIDValuesMutex sync.Mutex
+	customIDValuesNext  int
+)
+
+// storeCustomIDValue keeps value for a custom ID that is too long and returns
+// the key to put in the ID instead. Expired values are dropped on the way.
+func storeCustomIDValue(value interface{}) string {
+	customIDValuesMutex.Lock()
+	defer customIDValuesMutex.Unlock()
+
+	for k, v := range customIDValues {
+		if time.Since(v.Created) > customIDExpiry {
+			delete(customIDValues, k)
+		}
+	}
+	customIDValuesNext++
+	key := strconv.Itoa(customIDValuesNext)
+	customIDValues[key] = customIDValue{Value: value, Created: time.Now()}
+	return key
+}
+
+// loadCustomIDValue returns the value stored under key, or false when it has
+// expired or the bot was restarted since.
+func loadCustomIDValue(key string) (interface{}, bool) {
+	customIDValuesMutex.Lock()
+	defer customIDValuesMutex.Unlock()
+
+	v, ok := customIDValues[key]
+	if !ok || time.Since(v.Created) > customIDExpiry {
+		return nil, false
+	}
+	return v.Value, true
+}
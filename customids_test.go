@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCustomIDValueExpiry(t *testing.T) {
+	key := storeCustomIDValue(stopRef{Job: "deploy", Build: 1})
+	if value, ok := loadCustomIDValue(key); !ok || value.(stopRef).Build != 1 {
+		t.Fatalf("loadCustomIDValue(%q) = %v, %t", key, value, ok)
+	}
+
+	customIDValuesMutex.Lock()
+	v := customIDValues[key]
+	v.Created = time.Now().Add(-customIDExpiry - time.Minute)
+	customIDValues[key] = v
+	customIDValuesMutex.Unlock()
+
+	if _, ok := loadCustomIDValue(key); ok {
+		t.Errorf("expired value %q was still loaded", key)
+	}
+
+	// Storing drops expired values
+	storeCustomIDValue(stopRef{Job: "deploy", Build: 2})
+	customIDValuesMutex.Lock()
+	_, kept := customIDValues[key]
+	customIDValuesMutex.Unlock()
+	if kept {
+		t.Errorf("expired value %q was not evicted", key)
+	}
+}
+
+func TestLongCustomIDs(t *testing.T) {
+	job := "team/" + strings.Repeat("very-long-service-name-", 5) + "main"
+
+	inputID := inputButtonID(ActionProceed, inputRef{Job: job, Build: 7, InputID: "Approve"})
+	if len(inputID) > maxCustomIDLength {
+		t.Errorf("input button ID is %d characters", len(inputID))
+	}
+	action, ref, err := parseInputButtonID(inputID)
+	if err != nil || action != ActionProceed || ref != (inputRef{Job: job, Build: 7, InputID: "Approve"}) {
+		t.Errorf("parseInputButtonID(%q) = %q, %+v, %v", inputID, action, ref, err)
+	}
+
+	stopID := stopButtonID("confirm", stopRef{Job: job, Build: 7})
+	if len(stopID) > maxCustomIDLength {
+		t.Errorf("stop button ID is %d characters", len(stopID))
+	}
+	action, stop, err := parseStopButtonID(stopID)
+	if err != nil || action != "confirm" || stop != (stopRef{Job: job, Build: 7}) {
+		t.Errorf("parseStopButtonID(%q) = %q, %+v, %v", stopID, action, stop, err)
+	}
+
+	// A key of another kind of button doesn't parse
+	key := strings.TrimPrefix(stopID[strings.LastIndex(stopID, "|")+1:], "#")
+	if _, _, err := parseInputButtonID(inputButtonPrefix + "|proceed|#" + key); err == nil {
+		t.Errorf("an input button accepted the key of a stop button")
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"bot/jenkins"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DefaultIdentityFile is where linked Jenkins accounts are stored when the
+// config doesn't say otherwise.
+const DefaultIdentityFile = "identities.json"
+
+// IdentityConfig controls acting in Jenkins as the Discord user instead of as
+// the bot.
+type IdentityConfig struct {
+	// File stores the linked accounts, with tokens encrypted by IDENTITY_KEY.
+	File string `json:"file"`
+	// BotAccount lists who may fall back to the bot's own Jenkins account
+	// for which actions and jobs when they haven't linked one, in the same
+	// form as permissions. When empty everyone may.
+	BotAccount []PermissionRule `json:"botAccount"`
+}
+
+// linkedIdentity is a Jenkins account a Discord user linked, as stored on
+// disk. Token is the API token sealed with AES-GCM, nonce first.
+type linkedIdentity struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+// identityStore keeps the linked Jenkins accounts of Discord users per
+// instance in a JSON file. Tokens are only decrypted when they are used.
+type identityStore struct {
+	path string
+	aead cipher.AEAD
+
+	mu      sync.Mutex
+	entries map[string]map[string]linkedIdentity
+}
+
+// loadIdentityStore opens the store at path with the base64 encoded 32 byte
+// key. A missing file is an empty store.
+func loadIdentityStore(path, key string) (*identityStore, error) {
+	rawKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decoding IDENTITY_KEY: %w", err)
+	}
+	if len(rawKey) != 32 {
+		return nil, fmt.Errorf("IDENTITY_KEY must be 32 bytes, got %d", len(rawKey))
+	}
+	block, err := aes.NewCipher(rawKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &identityStore{path: path, aead: aead, entries: make(map[string]map[string]linkedIdentity)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.entries); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// additionalData binds a sealed token to the user, instance and account it
+// was linked for, so it can't be copied to another entry of the file.
+func additionalData(userID, instance, username string) []byte {
+	return []byte(userID + "\x00" + instance + "\x00" + username)
+}
+
+// link stores the Jenkins account of a Discord user on instance, replacing
+// any account linked before.
+func (store *identityStore) link(userID, instance, username, token string) error {
+	nonce := make([]byte, store.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := store.aead.Seal(nonce, nonce, []byte(token), additionalData(userID, instance, username))
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.entries[userID] == nil {
+		store.entries[userID] = make(map[string]linkedIdentity)
+	}
+	store.entries[userID][instance] = linkedIdentity{Username: username, Token: base64.StdEncoding.EncodeToString(sealed)}
+	return store.save()
+}
+
+// unlink forgets the account of a Discord user on instance and reports
+// whether there was one.
+func (store *identityStore) unlink(userID, instance string) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.entries[userID][instance]; !ok {
+		return false, nil
+	}
+	delete(store.entries[userID], instance)
+	if len(store.entries[userID]) == 0 {
+		delete(store.entries, userID)
+	}
+	return true, store.save()
+}
+
+// lookup returns the Jenkins username and API token a Discord user linked for
+// instance. ok is false when the user hasn't linked an account.
+func (store *identityStore) lookup(userID, instance string) (username, token string, ok bool, err error) {
+	store.mu.Lock()
+	identity, ok := store.entries[userID][instance]
+	store.mu.Unlock()
+	if !ok {
+		return "", "", false, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(identity.Token)
+	if err != nil || len(sealed) < store.aead.NonceSize() {
+		return "", "", false, errors.New("stored Jenkins token is corrupt, please /link again")
+	}
+	nonce, ciphertext := sealed[:store.aead.NonceSize()], sealed[store.aead.NonceSize():]
+	plain, err := store.aead.Open(nil, nonce, ciphertext, additionalData(userID, instance, identity.Username))
+	if err != nil {
+		return "", "", false, errors.New("stored Jenkins token can't be decrypted, please /link again")
+	}
+	return identity.Username, string(plain), true, nil
+}
+
+// save writes the store to a temporary file readable only by the bot and
+// moves it into place. The caller must hold mu.
+func (store *identityStore) save() error {
+	data, err := json.MarshalIndent(store.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), store.path)
+}
+
+// jenkinsJobAs is jenkinsJob for actions that change Jenkins: the client acts
+// as the Jenkins account who linked for the instance, or as the bot when they
+// haven't linked one and the config allows it.
+func (bot *Bot) jenkinsJobAs(who actor, action, name string) (*jenkins.Client, string, error) {
+	instance, job := splitInstance(name)
+	if instance == "" {
+		instance = bot.Config.DefaultJenkins
+	}
+	client, err := bot.jenkinsInstance(instance)
+	if err != nil {
+		return nil, "", err
+	}
+
+	linked, username, err := bot.linkedClient(who, instance)
+	if err != nil {
+		return nil, "", err
+	}
+	if linked != nil {
+		Logger.Printf("Acting as Jenkins user %s for %s (%s)\n", username, who.User.Username, who.User.ID)
+		return linked, job, nil
+	}
+
+	if !bot.allowsBotAccount(who, action, instance, job) {
+		return nil, "", fmt.Errorf("%s needs your own Jenkins account, link it with /link in a direct message to the bot", action)
+	}
+	return client, job, nil
+}
+
+// linkedClient returns a client acting as the Jenkins account who linked for
+// instance and its username, or a nil client when they haven't linked one.
+func (bot *Bot) linkedClient(who actor, instance string) (*jenkins.Client, string, error) {
+	if bot.identities == nil {
+		return nil, "", nil
+	}
+	username, token, ok, err := bot.identities.lookup(who.User.ID, instance)
+	if err != nil || !ok {
+		return nil, "", err
+	}
+	credentials := jenkins.BasicAuth{Username: username, Token: jenkins.StaticToken(token)}
+	return jenkins.NewClient(bot.Config.Jenkins[instance].URL, credentials), username, nil
+}
+
+// allowsBotAccount reports whether who may perform action on job through the
+// bot's Jenkins account.
+func (bot *Bot) allowsBotAccount(who actor, action, instance, job string) bool {
+	rules := bot.Config.Identities.BotAccount
+	if len(rules) == 0 {
+		return true
+	}
+	for _, rule := range rules {
+		if rule.allowsAction(action) && rule.allowsInstance(instance) && rule.allowsJob(job) && bot.ruleAppliesTo(rule, who) {
+			return true
+		}
+	}
+	return false
+}
+
+// dmOnly limits a command to DMs with the bot.
+var dmOnly = []discordgo.InteractionContextType{discordgo.InteractionContextBotDM}
+
+// linkCommands are used in DMs, so they are registered globally even when
+// the other commands are registered for a single guild.
+var linkCommands = []*discordgo.ApplicationCommand{
+	{
+		Name:        "link",
+		Description: "Links your Jenkins account so builds run as you",
+		Contexts:    &dmOnly,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "username",
+				Description: "Your Jenkins user ID",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "token",
+				Description: "An API token of your Jenkins user",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "instance",
+				Description: "Jenkins instance the account is on, defaults to the default instance",
+			},
+		},
+	},
+	{
+		Name:        "unlink",
+		Description: "Forgets your linked Jenkins account",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "instance",
+				Description: "Jenkins instance to unlink, defaults to the default instance",
+			},
+		},
+	},
+}
+
+// optionalInstance returns the instance option of a command, or the default
+// instance when it is left out.
+func (bot *Bot) optionalInstance(options map[string]*discordgo.ApplicationCommandInteractionDataOption) string {
+	if option, ok := options["instance"]; ok && strings.TrimSpace(option.StringValue()) != "" {
+		return strings.TrimSpace(option.StringValue())
+	}
+	return bot.Config.DefaultJenkins
+}
+
+// handleLinkCommand is the /link slash command. The token is checked against
+// Jenkins before it is stored.
+func (bot *Bot) handleLinkCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	if interaction.GuildID != "" {
+		bot.respondEphemeral(session, interaction, "🔒 /link only works in a direct message to the bot. Your token was not stored, consider revoking it in Jenkins")
+		return
+	}
+	if bot.identities == nil {
+		bot.respondEphemeral(session, interaction, "Linking Jenkins accounts is not enabled on this bot")
+		return
+	}
+
+	options := commandOptions(interaction)
+	username := strings.TrimSpace(options["username"].StringValue())
+	token := strings.TrimSpace(options["token"].StringValue())
+	instance := bot.optionalInstance(options)
+	if _, err := bot.jenkinsInstance(instance); err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	client := jenkins.NewClient(bot.Config.Jenkins[instance].URL, jenkins.BasicAuth{Username: username, Token: jenkins.StaticToken(token)})
+	me, err := client.Me()
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error checking the token with Jenkins: %v", err))
+		return
+	}
+	if !strings.EqualFold(me.ID, username) {
+		bot.editResponse(session, interaction, fmt.Sprintf("The token belongs to Jenkins user '%s', not '%s'", me.ID, username))
+		return
+	}
+
+	user := interactionUser(interaction)
+	if err := bot.identities.link(user.ID, instance, me.ID, token); err != nil {
+		Logger.Println("Error storing linked identity:", err)
+		bot.editResponse(session, interaction, fmt.Sprintf("Error storing your Jenkins account: %v", err))
+		return
+	}
+	Logger.Printf("Linked %s (%s) to Jenkins user %s on %s\n", user.Username, user.ID, me.ID, instance)
+	bot.editResponse(session, interaction, fmt.Sprintf("🔗 Linked to Jenkins user '%s' on %s, builds you start from Discord now run as you", me.ID, instance))
+}
+
+// handleUnlinkCommand is the /unlink slash command.
+func (bot *Bot) handleUnlinkCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	if bot.identities == nil {
+		bot.respondEphemeral(session, interaction, "Linking Jenkins accounts is not enabled on this bot")
+		return
+	}
+
+	instance := bot.optionalInstance(commandOptions(interaction))
+	user := interactionUser(interaction)
+	removed, err := bot.identities.unlink(user.ID, instance)
+	switch {
+	case err != nil:
+		Logger.Println("Error removing linked identity:", err)
+		bot.respondEphemeral(session, interaction, fmt.Sprintf("Error removing your Jenkins account: %v", err))
+	case !removed:
+		bot.respondEphemeral(session, interaction, fmt.Sprintf("You have no Jenkins account linked on %s", instance))
+	default:
+		Logger.Printf("Unlinked %s (%s) on %s\n", user.Username, user.ID, instance)
+		bot.respondEphemeral(session, interaction, fmt.Sprintf("Unlinked your Jenkins account on %s", instance))
+	}
+}
@@ -0,0 +1,641 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"bot/jenkins"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// inputRef identifies a pending input step of a specific build.
+type inputRef struct {
+	Job     string
+	Build   int
+	InputID string
+}
+
+// Custom IDs of input buttons have the form
+// "input|<action>|<build>|<input id>|<job>". Refs that do not fit are kept
+// with storeCustomIDValue and the button carries "input|<action>|#<key>"
+// instead.
+const inputButtonPrefix = "input"
+
+// inputActionForm is the button action proceeding an input step that asks for
+// parameters. It opens the form for them, and knowing that from the button
+// lets the others be answered without looking at the input first.
+const inputActionForm = "form"
+
+// inputButtonAction returns the button action answering input with action.
+func inputButtonAction(action string, input jenkins.PendingInput) string {
+	if action == ActionProceed && len(input.Inputs) > 0 {
+		return inputActionForm
+	}
+	return action
+}
+
+// inputButtonID encodes an action on a pending input into a button custom ID.
+func inputButtonID(action string, ref inputRef) string {
+	id := strings.Join([]string{inputButtonPrefix, action, strconv.Itoa(ref.Build), ref.InputID, ref.Job}, "|")
+	if len(id) <= maxCustomIDLength {
+		return id
+	}
+
+	return strings.Join([]string{inputButtonPrefix, action, "#" + storeCustomIDValue(ref)}, "|")
+}
+
+// parseInputButtonID is the inverse of inputButtonID.
+func parseInputButtonID(customID string) (string, inputRef, error) {
+	parts := strings.SplitN(customID, "|", 5)
+	if len(parts) < 3 || parts[0] != inputButtonPrefix {
+		return "", inputRef{}, fmt.Errorf("not an input button: %q", customID)
+	}
+	action := parts[1]
+
+	if key, ok := strings.CutPrefix(parts[2], "#"); ok {
+		value, _ := loadCustomIDValue(key)
+		ref, found := value.(inputRef)
+		if !found {
+			return "", inputRef{}, fmt.Errorf("this button has expired, answer the input with /proceed or /abort instead")
+		}
+		return action, ref, nil
+	}
+
+	if len(parts) != 5 {
+		return "", inputRef{}, fmt.Errorf("malformed input button: %q", customID)
+	}
+	build, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", inputRef{}, fmt.Errorf("malformed build number in %q", customID)
+	}
+	return action, inputRef{Job: parts[4], Build: build, InputID: parts[3]}, nil
+}
+
+// announcePendingInputs posts a message with Proceed/Abort buttons for every
+// input the build is waiting on that is not in announced yet, and records it
+// there so repeated polls don't post it twice.
+func (bot *Bot) announcePendingInputs(channelID, pipelineName string, buildNumber int, announced map[string]bool) {
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		Logger.Println("Error checking pending inputs:", err)
+		return
+	}
+
+	inputs, err := client.PendingInputs(job, buildNumber)
+	if err != nil {
+		Logger.Printf("Error fetching pending inputs of %s #%d: %v\n", pipelineName, buildNumber, err)
+		return
+	}
+
+	for _, input := range inputs {
+		if announced[input.ID] {
+			continue
+		}
+		announced[input.ID] = true
+		bot.postPendingInput(channelID, pipelineName, buildNumber, input)
+	}
+}
+
+// postPendingInput posts the message of an input step with buttons to answer it.
+func (bot *Bot) postPendingInput(channelID, pipelineName string, buildNumber int, input jenkins.PendingInput) {
+	ref := inputRef{Job: pipelineName, Build: buildNumber, InputID: input.ID}
+
+	content := fmt.Sprintf("⏸️ Jenkins pipeline '%s' #%d is waiting for input:\n> %s", pipelineName, buildNumber, input.Message)
+	if submitters := input.Submitters(); len(submitters) > 0 {
+		content += "\nOnly " + strings.Join(submitters, ", ") + " may answer it"
+	}
+
+	_, err := bot.Session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content: content,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    proceedLabel(input),
+						Style:    discordgo.SuccessButton,
+						CustomID: inputButtonID(inputButtonAction(ActionProceed, input), ref),
+					},
+					discordgo.Button{
+						Label:    "Abort",
+						Style:    discordgo.DangerButton,
+						CustomID: inputButtonID(ActionAbort, ref),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		Logger.Println("Error posting pending input:", err)
+	}
+}
+
+// proceedLabel returns the label of the proceed button of an input step.
+func proceedLabel(input jenkins.PendingInput) string {
+	if input.ProceedText == "" {
+		return "Proceed"
+	}
+	return input.ProceedText
+}
+
+// handleInputButton answers the input step referenced by a clicked button and
+// replaces the buttons with the outcome. The form button of an input step
+// that asks for parameters opens a form for them instead. Only that one looks
+// at the input before responding, so the others can't run into Discord's
+// deadline for the first response.
+func (bot *Bot) handleInputButton(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	action, ref, err := parseInputButtonID(interaction.MessageComponentData().CustomID)
+	if err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+	who := interactionActor(interaction)
+	if action == inputActionForm {
+		if err := bot.authorize(who, ActionProceed, ref.Job); err != nil {
+			bot.respondEphemeral(session, interaction, err.Error())
+			return
+		}
+		// A modal can only be the first response, so the input has to be
+		// looked at before responding
+		inputs, err := bot.findPendingInputs(ref.Job, ref.Build, ref.InputID)
+		if err != nil {
+			bot.respondEphemeral(session, interaction, fmt.Sprintf("Error answering input of '%s' #%d: %v", ref.Job, ref.Build, err))
+			return
+		}
+		input := inputs[0].PendingInput
+		// Don't ask for parameters that would be rejected anyway
+		if err := bot.authorizeSubmitter(who, ActionProceed, ref, input); err != nil {
+			bot.respondEphemeral(session, interaction, err.Error())
+			return
+		}
+		bot.openInputModal(session, interaction, ref, input)
+		return
+	}
+	if err := bot.authorize(who, action, ref.Job); err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+
+	err = session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		Logger.Println("Error deferring button response:", err)
+		return
+	}
+
+	inputs, err := bot.findPendingInputs(ref.Job, ref.Build, ref.InputID)
+	if err != nil {
+		bot.followupEphemeral(session, interaction, fmt.Sprintf("Error answering input of '%s' #%d: %v", ref.Job, ref.Build, err))
+		return
+	}
+	input := inputs[0].PendingInput
+	if action == ActionProceed && len(input.Inputs) > 0 {
+		// Buttons posted before the form action existed can't open it anymore
+		bot.followupEphemeral(session, interaction, fmt.Sprintf("The input of '%s' #%d asks for parameters, answer it with /proceed to fill them in", ref.Job, ref.Build))
+		return
+	}
+
+	if err := bot.answerInput(who, action, ref, input); err != nil {
+		bot.followupEphemeral(session, interaction, fmt.Sprintf("Error answering input of '%s' #%d: %v", ref.Job, ref.Build, err))
+		return
+	}
+	bot.updateAnsweredInput(session, interaction, action, nil)
+}
+
+// updateAnsweredInput replaces the buttons of an answered input message with
+// who answered it how, and with which parameters.
+func (bot *Bot) updateAnsweredInput(session *discordgo.Session, interaction *discordgo.InteractionCreate, action string, parameters map[string]string) {
+	outcome := "✅ Proceeded"
+	if action == ActionAbort {
+		outcome = "🛑 Aborted"
+	}
+	content := fmt.Sprintf("%s\n%s by %s", interaction.Message.Content, outcome, interactionUser(interaction).Mention())
+	if len(parameters) > 0 {
+		content += " with " + formatParameterValues(parameters)
+	}
+	components := []discordgo.MessageComponent{}
+	_, err := session.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &components,
+	})
+	if err != nil {
+		Logger.Println("Error updating input message:", err)
+	}
+}
+
+// answerInput proceeds or aborts the input step ref for who.
+func (bot *Bot) answerInput(who actor, action string, ref inputRef, input jenkins.PendingInput) error {
+	if err := bot.authorizeSubmitter(who, action, ref, input); err != nil {
+		return err
+	}
+
+	client, job, err := bot.jenkinsJobAs(who, action, ref.Job)
+	if err == nil {
+		Logger.Printf("Answering input %s of %s #%d with %s for %s (%s)\n", ref.InputID, ref.Job, ref.Build, action, who.User.Username, who.User.ID)
+		switch action {
+		case ActionProceed:
+			err = client.ProceedInput(job, ref.Build, ref.InputID)
+		case ActionAbort:
+			err = client.AbortInput(job, ref.Build, ref.InputID)
+		default:
+			err = fmt.Errorf("unknown action %q", action)
+		}
+	}
+	bot.audit(who, auditEntry{Action: action, Job: ref.Job, Build: ref.Build}, err)
+	if err != nil {
+		Logger.Printf("Error answering input %s of %s #%d: %v\n", ref.InputID, ref.Job, ref.Build, err)
+	}
+	return err
+}
+
+// authorizeSubmitter checks that who may answer an input step whose submitter
+// restricts it to some Jenkins users and groups, and records denials in the
+// audit log. The bot's own account may be allowed to answer anything, so who
+// has to have linked a Jenkins account that is one of the submitters or a
+// member of one of the groups. When Jenkins doesn't report the submitter, who
+// has to act as their linked account so that Jenkins can check it instead.
+func (bot *Bot) authorizeSubmitter(who actor, action string, ref inputRef, input jenkins.PendingInput) error {
+	submitters := input.Submitters()
+	if len(submitters) == 0 && !input.SubmitterUnknown {
+		return nil
+	}
+	instance, _ := splitInstance(ref.Job)
+	if instance == "" {
+		instance = bot.Config.DefaultJenkins
+	}
+
+	deny := func(reason string) error {
+		Logger.Printf("Denied %s on input %s of %s #%d for %s (%s): %s\n", action, ref.InputID, ref.Job, ref.Build, who.User.Username, who.User.ID, reason)
+		bot.audit(who, auditEntry{Action: action, Job: ref.Job, Build: ref.Build, Outcome: OutcomeDenied}, nil)
+		return fmt.Errorf("🚫 %s, %s", who.User.Mention(), reason)
+	}
+
+	client, username, err := bot.linkedClient(who, instance)
+	if err != nil {
+		return err
+	}
+	if input.SubmitterUnknown {
+		if client == nil {
+			return deny(fmt.Sprintf("Jenkins didn't report who may answer this input of '%s' #%d, link your Jenkins account with /link in a direct message to the bot so Jenkins can check", ref.Job, ref.Build))
+		}
+		return nil
+	}
+
+	only := fmt.Sprintf("only %s may answer this input of '%s' #%d", strings.Join(submitters, ", "), ref.Job, ref.Build)
+	if client == nil {
+		return deny(only + ", link your Jenkins account with /link in a direct message to the bot if you are one of them")
+	}
+
+	authentication, err := client.WhoAmI()
+	if err != nil {
+		return fmt.Errorf("failed to look up your Jenkins account: %w", err)
+	}
+	names := append([]string{authentication.Name}, authentication.Authorities...)
+	for _, submitter := range submitters {
+		for _, name := range names {
+			if strings.EqualFold(submitter, name) {
+				return nil
+			}
+		}
+	}
+	return deny(fmt.Sprintf("%s, your Jenkins account %s is not one of them", only, username))
+}
+
+// proceedInputWithParameters validates supplied against the parameters the
+// input step ref asks for and proceeds it with them for who. Parameters that
+// are not supplied get their default. It returns the submitted values with
+// passwords masked.
+func (bot *Bot) proceedInputWithParameters(who actor, ref inputRef, input jenkins.PendingInput, supplied map[string]string) (map[string]string, error) {
+	if err := bot.authorizeSubmitter(who, ActionProceed, ref, input); err != nil {
+		return nil, err
+	}
+
+	definitions := input.Parameters()
+	entry := auditEntry{Action: ActionProceed, Job: ref.Job, Build: ref.Build}
+
+	values, err := inputParameterValues(ref, definitions, supplied)
+	if err == nil {
+		entry.Parameters = maskParameters(definitions, values)
+		var client *jenkins.Client
+		var job string
+		client, job, err = bot.jenkinsJobAs(who, ActionProceed, ref.Job)
+		if err == nil {
+			Logger.Printf("Answering input %s of %s #%d with %s %v for %s (%s)\n", ref.InputID, ref.Job, ref.Build, ActionProceed, entry.Parameters, who.User.Username, who.User.ID)
+			err = client.ProceedInputWithParameters(job, ref.Build, ref.InputID, proceedLabel(input), values)
+		}
+	}
+	bot.audit(who, entry, err)
+	if err != nil {
+		Logger.Printf("Error answering input %s of %s #%d: %v\n", ref.InputID, ref.Job, ref.Build, err)
+		return nil, err
+	}
+	return entry.Parameters, nil
+}
+
+// inputParameterValues validates supplied against the parameters of the input
+// step ref and returns the complete set of values to submit. Unlike
+// buildWithParameters, Jenkins doesn't fill in defaults for parameters an
+// input submission leaves out, so every parameter gets its default here.
+// Choices without one get their first choice and booleans false, as in
+// Jenkins, while passwords without one have to be supplied.
+func inputParameterValues(ref inputRef, definitions []jenkins.ParameterDefinition, supplied map[string]string) (map[string]string, error) {
+	if len(definitions) == 0 && len(supplied) > 0 {
+		return nil, fmt.Errorf("input '%s' does not take parameters", ref.InputID)
+	}
+	values, err := validateParameters(ref.Job, definitions, supplied)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, definition := range definitions {
+		if _, ok := values[definition.Name]; ok {
+			continue
+		}
+		value := definition.Default()
+		if value == "" {
+			// Fall back the way Jenkins does for parameters without a default
+			switch definition.Type {
+			case jenkins.PasswordParameter:
+				return nil, fmt.Errorf("password %s has no default and has to be given", definition.Name)
+			case jenkins.ChoiceParameter:
+				if len(definition.Choices) > 0 {
+					value = definition.Choices[0]
+				}
+			case jenkins.BooleanParameter:
+				value = "false"
+			}
+		}
+		values[definition.Name] = value
+	}
+	return values, nil
+}
+
+// openInputModal responds to interaction with a modal asking for the
+// parameters of an input step, prefilled with their defaults. Choices and
+// booleans are typed in too, as modals only hold text inputs. Parameters
+// that don't fit keep their default.
+func (bot *Bot) openInputModal(session *discordgo.Session, interaction *discordgo.InteractionCreate, ref inputRef, input jenkins.PendingInput) {
+	definitions := input.Parameters()
+	if len(definitions) > maxModalInputs {
+		Logger.Printf("Input form for %s #%d leaves %s at their defaults\n", ref.Job, ref.Build, strings.Join(parameterNames(definitions[maxModalInputs:]), ", "))
+		definitions = definitions[:maxModalInputs]
+	}
+
+	var rows []discordgo.MessageComponent
+	for _, definition := range definitions {
+		style := discordgo.TextInputShort
+		if definition.Type == jenkins.TextParameter {
+			style = discordgo.TextInputParagraph
+		}
+
+		placeholder := definition.Description
+		switch definition.Type {
+		case jenkins.ChoiceParameter:
+			placeholder = "One of: " + strings.Join(definition.Choices, ", ")
+		case jenkins.BooleanParameter:
+			placeholder = "true or false"
+		}
+
+		value := ""
+		if definition.Type != jenkins.PasswordParameter {
+			value = definition.Default()
+		}
+		// A blank password without a default can't be submitted
+		required := definition.Type == jenkins.PasswordParameter && definition.Default() == ""
+
+		rows = append(rows, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextInput{
+					CustomID:    definition.Name,
+					Label:       truncate(definition.Name, maxInputLabel),
+					Style:       style,
+					Placeholder: truncate(placeholder, 100),
+					Value:       truncate(value, maxInputValueChars),
+					Required:    required,
+					MaxLength:   maxInputValueChars,
+				},
+			},
+		})
+	}
+
+	err := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID:   inputButtonID("submit", ref),
+			Title:      truncate(fmt.Sprintf("%s %s #%d", proceedLabel(input), ref.Job, ref.Build), maxModalTitle),
+			Components: rows,
+		},
+	})
+	if err != nil {
+		Logger.Println("Error opening input modal:", err)
+	}
+}
+
+// handleInputModal proceeds an input step with the parameters entered in its
+// modal and updates the input message like a button would.
+func (bot *Bot) handleInputModal(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	data := interaction.ModalSubmitData()
+	_, ref, err := parseInputButtonID(data.CustomID)
+	if err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+	who := interactionActor(interaction)
+	if err := bot.authorize(who, ActionProceed, ref.Job); err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+
+	err = session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		Logger.Println("Error deferring modal response:", err)
+		return
+	}
+
+	inputs, err := bot.findPendingInputs(ref.Job, ref.Build, ref.InputID)
+	if err != nil {
+		bot.followupEphemeral(session, interaction, fmt.Sprintf("Error answering input of '%s' #%d: %v", ref.Job, ref.Build, err))
+		return
+	}
+	input := inputs[0].PendingInput
+
+	types := make(map[string]string)
+	for _, definition := range input.Parameters() {
+		types[definition.Name] = definition.Type
+	}
+	supplied := make(map[string]string)
+	for _, row := range data.Components {
+		actionsRow, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actionsRow.Components {
+			textInput, ok := component.(*discordgo.TextInput)
+			if !ok {
+				continue
+			}
+			// Blank fields that can't be empty keep their default
+			if textInput.Value == "" && types[textInput.CustomID] != jenkins.StringParameter && types[textInput.CustomID] != jenkins.TextParameter {
+				continue
+			}
+			supplied[textInput.CustomID] = textInput.Value
+		}
+	}
+
+	parameters, err := bot.proceedInputWithParameters(who, ref, input, supplied)
+	if err != nil {
+		bot.followupEphemeral(session, interaction, fmt.Sprintf("Error answering input of '%s' #%d: %v", ref.Job, ref.Build, err))
+		return
+	}
+	bot.updateAnsweredInput(session, interaction, ActionProceed, parameters)
+}
+
+// pendingInput is an input step with the build waiting on it.
+type pendingInput struct {
+	Build int
+	jenkins.PendingInput
+}
+
+// maxRunningBuilds is how many of the newest builds of a job are searched for
+// pending inputs.
+const maxRunningBuilds = 50
+
+// findPendingInputs returns the input steps of a pipeline matching number and
+// inputID. When number is 0 the inputs of all running builds are returned,
+// and when inputID is empty all inputs of the build. It fails when there is
+// none.
+func (bot *Bot) findPendingInputs(pipelineName string, number int, inputID string) ([]pendingInput, error) {
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := []int{number}
+	if number == 0 {
+		builds, err := client.Builds(job, maxRunningBuilds)
+		if err != nil {
+			return nil, err
+		}
+		numbers = numbers[:0]
+		for _, build := range builds {
+			if build.Running() {
+				numbers = append(numbers, build.Number)
+			}
+		}
+		if len(numbers) == 0 {
+			return nil, fmt.Errorf("Jenkins pipeline '%s' has no running builds", pipelineName)
+		}
+	}
+
+	var found []pendingInput
+	for _, n := range numbers {
+		inputs, err := client.PendingInputs(job, n)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch pending inputs of #%d: %w", n, err)
+		}
+		for _, input := range inputs {
+			if inputID == "" || input.ID == inputID {
+				found = append(found, pendingInput{Build: n, PendingInput: input})
+			}
+		}
+	}
+
+	switch {
+	case len(found) > 0:
+		return found, nil
+	case inputID != "":
+		return nil, fmt.Errorf("no pending input '%s' found", inputID)
+	case number != 0:
+		return nil, fmt.Errorf("build #%d is not waiting for input", number)
+	default:
+		return nil, fmt.Errorf("no running build is waiting for input")
+	}
+}
+
+// maxInputChoices is how many buttons fit in a message.
+const maxInputChoices = 25
+
+// inputChoices asks which of several pending inputs action is meant for, with
+// a button per input. A single input is one asking for parameters, whose
+// button opens the form for them.
+func inputChoices(action, pipelineName string, inputs []pendingInput) (string, []discordgo.MessageComponent) {
+	style := discordgo.SuccessButton
+	if action == ActionAbort {
+		style = discordgo.DangerButton
+	}
+
+	var content strings.Builder
+	if len(inputs) == 1 {
+		input := inputs[0]
+		content.WriteString(fmt.Sprintf("⏸️ Jenkins pipeline '%s' #%d is waiting for input with parameters, press the button to fill them in:\n> %s\nParameters: %s",
+			pipelineName, input.Build, input.Message, strings.Join(parameterNames(input.Parameters()), ", ")))
+		button := discordgo.Button{
+			Label:    truncate(proceedLabel(input.PendingInput), 80),
+			Style:    style,
+			CustomID: inputButtonID(inputButtonAction(action, input.PendingInput), inputRef{Job: pipelineName, Build: input.Build, InputID: input.ID}),
+		}
+		return truncate(content.String(), maxMessageLength), []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{button}},
+		}
+	}
+	content.WriteString(fmt.Sprintf("Jenkins pipeline '%s' is waiting for %d inputs, pick the one to %s:", pipelineName, len(inputs), action))
+	if len(inputs) > maxInputChoices {
+		inputs = inputs[:maxInputChoices]
+	}
+
+	var rows []discordgo.MessageComponent
+	var buttons []discordgo.MessageComponent
+	for _, input := range inputs {
+		content.WriteString(fmt.Sprintf("\n#%d `%s`: %s", input.Build, input.ID, input.Message))
+
+		label := input.Message
+		if label == "" {
+			label = input.ID
+		}
+		buttons = append(buttons, discordgo.Button{
+			Label:    truncate(fmt.Sprintf("#%d %s", input.Build, label), 80),
+			Style:    style,
+			CustomID: inputButtonID(inputButtonAction(action, input.PendingInput), inputRef{Job: pipelineName, Build: input.Build, InputID: input.ID}),
+		})
+		if len(buttons) == 5 {
+			rows = append(rows, discordgo.ActionsRow{Components: buttons})
+			buttons = nil
+		}
+	}
+	if len(buttons) > 0 {
+		rows = append(rows, discordgo.ActionsRow{Components: buttons})
+	}
+	return truncate(content.String(), maxMessageLength), rows
+}
+
+// parseInputTarget splits the arguments of !proceed and !abort into a
+// pipeline name, an optional build number and an optional input ID, which
+// can only be given after a build number. A trailing number is always taken
+// as the build, so "release 2024 12" is build 12 of "release 2024" rather
+// than input 12 of build 2024.
+func parseInputTarget(args []string) (string, int, string) {
+	if _, err := strconv.Atoi(strings.TrimPrefix(args[len(args)-1], "#")); err == nil {
+		name, number := parsePipelineAndBuild(args)
+		return name, number, ""
+	}
+	if len(args) > 2 {
+		if number, err := strconv.Atoi(strings.TrimPrefix(args[len(args)-2], "#")); err == nil && number > 0 {
+			return strings.Join(args[:len(args)-2], " "), number, args[len(args)-1]
+		}
+	}
+	name, number := parsePipelineAndBuild(args)
+	return name, number, ""
+}
+
+// interactionUser returns the user behind an interaction, which is in Member
+// for guild interactions and in User for DMs.
+func interactionUser(interaction *discordgo.InteractionCreate) *discordgo.User {
+	if interaction.Member != nil {
+		return interaction.Member.User
+	}
+	return interaction.User
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot/jenkins"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// newSubmitterTestBot returns a bot whose default instance is a test Jenkins
+// that authenticates every linked user as alice in the release-managers
+// group.
+func newSubmitterTestBot(t *testing.T) *Bot {
+	t.Helper()
+	Logger = log.New(io.Discard, "", 0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/whoAmI/api/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"name": "alice", "authorities": ["authenticated", "release-managers"]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	identities, err := loadIdentityStore(filepath.Join(dir, "identities.json"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := identities.link("linked", "main", "alice", "token"); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Bot{
+		Config: &Config{
+			Jenkins:        map[string]JenkinsInstance{"main": {URL: server.URL}},
+			DefaultJenkins: "main",
+		},
+		identities: identities,
+		auditLog:   &auditLog{path: filepath.Join(dir, "audit.jsonl")},
+	}
+}
+
+func TestAuthorizeSubmitter(t *testing.T) {
+	bot := newSubmitterTestBot(t)
+	ref := inputRef{Job: "deploy", Build: 3, InputID: "Approve"}
+
+	tests := []struct {
+		name    string
+		userID  string
+		input   jenkins.PendingInput
+		allowed bool
+	}{
+		{"unrestricted", "unlinked", jenkins.PendingInput{}, true},
+		{"restricted, not linked", "unlinked", jenkins.PendingInput{Submitter: "alice"}, false},
+		{"restricted to the user", "linked", jenkins.PendingInput{Submitter: "Alice"}, true},
+		{"restricted to a group of the user", "linked", jenkins.PendingInput{Submitter: "bob, release-managers"}, true},
+		{"restricted to others", "linked", jenkins.PendingInput{Submitter: "bob,admins"}, false},
+		{"unknown submitter, not linked", "unlinked", jenkins.PendingInput{SubmitterUnknown: true}, false},
+		{"unknown submitter, linked", "linked", jenkins.PendingInput{SubmitterUnknown: true}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			who := actor{ChannelID: "channel", User: &discordgo.User{ID: test.userID, Username: test.userID}}
+			err := bot.authorizeSubmitter(who, ActionProceed, ref, test.input)
+			if (err == nil) != test.allowed {
+				t.Errorf("authorizeSubmitter() = %v, want allowed %t", err, test.allowed)
+			}
+		})
+	}
+}
+
+func TestAuthorizeSubmitterAuditsDenials(t *testing.T) {
+	bot := newSubmitterTestBot(t)
+	who := actor{ChannelID: "channel", User: &discordgo.User{ID: "unlinked", Username: "mallory"}}
+	ref := inputRef{Job: "deploy", Build: 3, InputID: "Approve"}
+
+	err := bot.authorizeSubmitter(who, ActionProceed, ref, jenkins.PendingInput{SubmitterUnknown: true})
+	if err == nil || !strings.Contains(err.Error(), "/link") {
+		t.Fatalf("authorizeSubmitter() = %v, want a denial pointing to /link", err)
+	}
+
+	entries, err := bot.auditLog.search(auditQuery{}, auditLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Outcome != OutcomeDenied || entries[0].Build != 3 {
+		t.Errorf("audit log after a denial: %+v", entries)
+	}
+}
+
+func TestInputButtonAction(t *testing.T) {
+	withParameters := jenkins.PendingInput{Inputs: []jenkins.InputParameter{{Name: "VERSION", Type: jenkins.StringParameter}}}
+
+	tests := []struct {
+		name   string
+		action string
+		input  jenkins.PendingInput
+		want   string
+	}{
+		{"proceed without parameters", ActionProceed, jenkins.PendingInput{}, ActionProceed},
+		{"proceed with parameters", ActionProceed, withParameters, inputActionForm},
+		{"abort with parameters", ActionAbort, withParameters, ActionAbort},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := inputButtonAction(test.action, test.input); got != test.want {
+				t.Errorf("inputButtonAction() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseInputTarget(t *testing.T) {
+	tests := []struct {
+		args    string
+		name    string
+		build   int
+		inputID string
+	}{
+		{"deploy", "deploy", 0, ""},
+		{"deploy 12", "deploy", 12, ""},
+		{"deploy #12", "deploy", 12, ""},
+		{"my job", "my job", 0, ""},
+		{"my job 12", "my job", 12, ""},
+		{"my job #12", "my job", 12, ""},
+		{"deploy 12 input-abc", "deploy", 12, "input-abc"},
+		{"deploy #12 Approve", "deploy", 12, "Approve"},
+		{"my job 12 Approve", "my job", 12, "Approve"},
+		{"release 2024 12", "release 2024", 12, ""},
+		{"team/service/feature%2Flogin 3", "team/service/feature%2Flogin", 3, ""},
+		{"deploy now please", "deploy now please", 0, ""},
+		{"deploy 0", "deploy 0", 0, ""},
+		{"12", "12", 0, ""},
+	}
+	for _, test := range tests {
+		name, build, inputID := parseInputTarget(strings.Fields(test.args))
+		if name != test.name || build != test.build || inputID != test.inputID {
+			t.Errorf("parseInputTarget(%q) = %q, %d, %q, want %q, %d, %q",
+				test.args, name, build, inputID, test.name, test.build, test.inputID)
+		}
+	}
+}
+
+func TestInputParameterValues(t *testing.T) {
+	ref := inputRef{Job: "deploy", Build: 3, InputID: "Approve"}
+	definitions := []jenkins.ParameterDefinition{
+		{Name: "ENV", Type: jenkins.ChoiceParameter, Choices: []string{"staging", "prod"}, DefaultParameterValue: &jenkins.ParameterValue{Value: "staging"}},
+		{Name: "NOTES", Type: jenkins.StringParameter},
+		{Name: "TOKEN", Type: jenkins.PasswordParameter, DefaultParameterValue: &jenkins.ParameterValue{Value: "default-token"}},
+	}
+	withSecret := append(definitions[:len(definitions):len(definitions)], jenkins.ParameterDefinition{Name: "SECRET", Type: jenkins.PasswordParameter})
+
+	tests := []struct {
+		name        string
+		definitions []jenkins.ParameterDefinition
+		supplied    map[string]string
+		want        map[string]string
+		wantErr     string
+	}{
+		{
+			name:        "every parameter left out gets its default, passwords too",
+			definitions: definitions,
+			supplied:    map[string]string{},
+			want:        map[string]string{"ENV": "staging", "NOTES": "", "TOKEN": "default-token"},
+		},
+		{
+			name:        "supplied values win",
+			definitions: definitions,
+			supplied:    map[string]string{"ENV": "prod", "TOKEN": "mine"},
+			want:        map[string]string{"ENV": "prod", "NOTES": "", "TOKEN": "mine"},
+		},
+		{
+			name:        "password without a default must be given",
+			definitions: withSecret,
+			supplied:    map[string]string{"ENV": "prod"},
+			wantErr:     "password SECRET has no default",
+		},
+		{
+			name:        "password without a default given",
+			definitions: withSecret,
+			supplied:    map[string]string{"SECRET": "hunter2"},
+			want:        map[string]string{"ENV": "staging", "NOTES": "", "TOKEN": "default-token", "SECRET": "hunter2"},
+		},
+		{
+			name: "choice without a default gets its first choice",
+			definitions: []jenkins.ParameterDefinition{
+				{Name: "REGION", Type: jenkins.ChoiceParameter, Choices: []string{"eu", "us"}},
+			},
+			supplied: map[string]string{},
+			want:     map[string]string{"REGION": "eu"},
+		},
+		{
+			name: "boolean without a default is false",
+			definitions: []jenkins.ParameterDefinition{
+				{Name: "FORCE", Type: jenkins.BooleanParameter},
+			},
+			supplied: map[string]string{},
+			want:     map[string]string{"FORCE": "false"},
+		},
+		{
+			name:     "input without parameters",
+			supplied: map[string]string{"ENV": "prod"},
+			wantErr:  "does not take parameters",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := inputParameterValues(ref, test.definitions, test.supplied)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("inputParameterValues() error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("inputParameterValues() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
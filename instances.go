@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"bot/jenkins"
+)
+
+// DefaultInstance names the Jenkins instance configured through JENKINS_URL
+// and JENKINS_TOKEN when the config doesn't list any.
+const DefaultInstance = "default"
+
+// JenkinsInstance is a Jenkins controller the bot can talk to.
+type JenkinsInstance struct {
+	URL string `json:"url"`
+	// Username is the Jenkins user the API token belongs to.
+	Username string `json:"username"`
+	// The token is given inline, read from a file or read from a Docker
+	// secret of that name. Files are re-read when they change.
+	Token       string `json:"token"`
+	TokenFile   string `json:"tokenFile"`
+	TokenSecret string `json:"tokenSecret"`
+	// Bearer sends the token as a bearer token instead of with Username as
+	// HTTP Basic credentials.
+	Bearer bool `json:"bearer"`
+}
+
+// credentials returns the credentials requests to the instance are sent
+// with, or nil when none are configured.
+func (instance JenkinsInstance) credentials() (jenkins.Credentials, error) {
+	var token jenkins.TokenSource
+	switch {
+	case instance.TokenFile != "" && instance.TokenSecret != "":
+		return nil, errors.New("only one of tokenFile and tokenSecret may be set")
+	case instance.TokenFile != "":
+		file, err := jenkins.NewTokenFile(instance.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = file
+	case instance.TokenSecret != "":
+		file, err := jenkins.NewTokenFile(filepath.Join(jenkins.DockerSecretDir, instance.TokenSecret))
+		if err != nil {
+			return nil, err
+		}
+		token = file
+	case instance.Token != "":
+		token = jenkins.StaticToken(instance.Token)
+	default:
+		return nil, nil
+	}
+
+	if instance.Bearer {
+		return jenkins.BearerAuth{Token: token}, nil
+	}
+	if instance.Username == "" {
+		return nil, errors.New("username is required unless bearer is set")
+	}
+	return jenkins.BasicAuth{Username: instance.Username, Token: token}, nil
+}
+
+// setupInstances creates a client per configured Jenkins instance and picks
+// the default one.
+func (bot *Bot) setupInstances() error {
+	if len(bot.Config.Jenkins) == 0 {
+		bot.Config.Jenkins = map[string]JenkinsInstance{
+			DefaultInstance: {URL: JenkinsURL, Username: JenkinsUser, Token: JenkinsToken, TokenFile: JenkinsTokenFile},
+		}
+	}
+
+	bot.instances = make(map[string]*jenkins.Client)
+	for name, instance := range bot.Config.Jenkins {
+		if name == "" || strings.Contains(name, ":") {
+			return fmt.Errorf("invalid Jenkins instance name %q", name)
+		}
+		if instance.URL == "" {
+			return fmt.Errorf("Jenkins instance %q has no url", name)
+		}
+		credentials, err := instance.credentials()
+		if err != nil {
+			return fmt.Errorf("Jenkins instance %q: %w", name, err)
+		}
+		bot.instances[name] = jenkins.NewClient(instance.URL, credentials)
+		bot.instanceNames = append(bot.instanceNames, name)
+	}
+	sort.Strings(bot.instanceNames)
+
+	if bot.Config.DefaultJenkins == "" {
+		bot.Config.DefaultJenkins = bot.instanceNames[0]
+		if len(bot.instanceNames) > 1 {
+			Logger.Printf("No defaultJenkins configured, using %s\n", bot.Config.DefaultJenkins)
+		}
+	}
+	if _, ok := bot.instances[bot.Config.DefaultJenkins]; !ok {
+		return fmt.Errorf("defaultJenkins %q is not a configured instance", bot.Config.DefaultJenkins)
+	}
+	return nil
+}
+
+// multipleInstances reports whether job names need an instance prefix.
+func (bot *Bot) multipleInstances() bool {
+	return len(bot.instances) > 1
+}
+
+// splitInstance splits a job name of the form instance:job. Jenkins doesn't
+// allow ':' in job names, so the first one always separates the instance.
+func splitInstance(name string) (string, string) {
+	if instance, job, ok := strings.Cut(name, ":"); ok {
+		return strings.TrimSpace(instance), strings.TrimSpace(job)
+	}
+	return "", name
+}
+
+// qualifiedName is the name commands use for job on instance. The instance is
+// only spelled out when there is more than one.
+func (bot *Bot) qualifiedName(instance, job string) string {
+	if !bot.multipleInstances() {
+		return job
+	}
+	return instance + ":" + job
+}
+
+// channelInstance returns the instance commands in a channel default to: the
+// one configured for the channel, then for the guild, then the global default.
+func (bot *Bot) channelInstance(guildID, channelID string) string {
+	if instance, ok := bot.Config.ChannelJenkins[channelID]; ok {
+		return instance
+	}
+	if instance, ok := bot.Config.GuildJenkins[guildID]; ok {
+		return instance
+	}
+	return bot.Config.DefaultJenkins
+}
+
+// qualifyJob resolves a job name given in a command to the name used from
+// then on, so builds stay tied to the right instance after the command.
+func (bot *Bot) qualifyJob(name, guildID, channelID string) string {
+	if !bot.multipleInstances() {
+		_, job := splitInstance(name)
+		return job
+	}
+	if instance, _ := splitInstance(name); instance != "" {
+		return name
+	}
+	return bot.qualifiedName(bot.channelInstance(guildID, channelID), name)
+}
+
+// jenkinsJob returns the client of the instance a (possibly qualified) job
+// name refers to and the job name on that instance.
+func (bot *Bot) jenkinsJob(name string) (*jenkins.Client, string, error) {
+	instance, job := splitInstance(name)
+	client, err := bot.jenkinsInstance(instance)
+	return client, job, err
+}
+
+// jenkinsInstance returns the client of the named instance, or of the default
+// instance when name is empty.
+func (bot *Bot) jenkinsInstance(name string) (*jenkins.Client, error) {
+	if name == "" {
+		name = bot.Config.DefaultJenkins
+	}
+	client, ok := bot.instances[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown Jenkins instance '%s', expected one of: %s", name, strings.Join(bot.instanceNames, ", "))
+	}
+	return client, nil
+}
+
+// instanceForURL returns the instance whose URL the given Jenkins URL is
+// under, or the default instance.
+func (bot *Bot) instanceForURL(jenkinsURL string) string {
+	for _, name := range bot.instanceNames {
+		base := strings.TrimRight(bot.Config.Jenkins[name].URL, "/") + "/"
+		if strings.HasPrefix(jenkinsURL, base) {
+			return name
+		}
+	}
+	return bot.Config.DefaultJenkins
+}
@@ -0,0 +1,177 @@
+// Package jenkins is a small client for the parts of the Jenkins REST API the
+// bot relies on: listing jobs, reading builds, triggering pipelines and
+// answering pending input steps.
+package jenkins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client talks to a single Jenkins controller.
+type Client struct {
+	// BaseURL is the root of the Jenkins instance, e.g. http://jenkins:8080.
+	BaseURL string
+	// HTTPClient is used to perform requests. http.DefaultClient is used when
+	// nil. Authentication is left to its transport, see Transport.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a Client for the Jenkins instance at baseURL that sends
+// credentials with every request to its host. credentials may be nil for
+// anonymous access.
+func NewClient(baseURL string, credentials Credentials) *Client {
+	transport := &Transport{Credentials: credentials}
+	if parsed, err := url.Parse(baseURL); err == nil {
+		transport.Host = parsed.Host
+	}
+	if transport.Host == "" {
+		// Without a host to compare against, don't send credentials anywhere
+		transport.Credentials = nil
+	}
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Transport: transport},
+	}
+}
+
+// StatusError is returned when Jenkins answers with a non-2xx status code.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP request failed with status: %s", e.Status)
+}
+
+// IsNotFound reports whether err is a 404 from Jenkins.
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// do performs a request against path, which is relative to BaseURL and may
+// carry a query string. Non-2xx responses are turned into a *StatusError and
+// their body is discarded; on success the caller must close the body.
+func (c *Client) do(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(c.BaseURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(req)
+}
+
+// send performs req, turning non-2xx responses into a *StatusError.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return resp, nil
+}
+
+// getJSON fetches path and decodes the JSON response into v.
+func (c *Client) getJSON(path string, v interface{}) error {
+	resp, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// post sends an empty POST to path and returns the response headers.
+func (c *Client) post(path string) (http.Header, error) {
+	resp, err := c.do(http.MethodPost, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.Header, nil
+}
+
+// postForm POSTs form to path as application/x-www-form-urlencoded.
+func (c *Client) postForm(path string, form url.Values) error {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(c.BaseURL, "/")+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// jobPath returns the URL path of the named job. Jobs inside folders and
+// multibranch projects are named by their full name, e.g. team/service/main,
+// which becomes /job/team/job/service/job/main. Each segment is escaped on
+// its own, so a branch Jenkins lists as feature%2Flogin stays one segment.
+func jobPath(name string) string {
+	var path strings.Builder
+	for _, segment := range strings.Split(strings.Trim(name, "/"), "/") {
+		path.WriteString("/job/")
+		path.WriteString(url.PathEscape(segment))
+	}
+	return path.String()
+}
+
+// buildPath returns the URL path of a numbered build of the named job.
+func buildPath(name string, number int) string {
+	return fmt.Sprintf("%s/%d", jobPath(name), number)
+}
+
+// JobNameFromURL is the inverse of the job paths the client builds: it turns
+// a job or build URL such as http://jenkins/job/team/job/service/42/ or the
+// relative job/team/job/service/ into the full job name team/service.
+func JobNameFromURL(jobURL string) string {
+	path := jobURL
+	if parsed, err := url.Parse(jobURL); err == nil {
+		path = parsed.EscapedPath()
+	}
+
+	var segments []string
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] != "job" {
+			continue
+		}
+		segment, err := url.PathUnescape(parts[i+1])
+		if err != nil {
+			segment = parts[i+1]
+		}
+		segments = append(segments, segment)
+		i++
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,70 @@
+package jenkins
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestClient returns a client for a test server answering with handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, nil)
+}
+
+func TestJobPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"deploy", "/job/deploy"},
+		{"team/service/main", "/job/team/job/service/job/main"},
+		{"/team/service/", "/job/team/job/service"},
+		{"my job", "/job/my%20job"},
+		{"service/feature%2Flogin", "/job/service/job/feature%252Flogin"},
+	}
+	for _, test := range tests {
+		if got := jobPath(test.name); got != test.want {
+			t.Errorf("jobPath(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestJobNameFromURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://jenkins:8080/job/deploy/", "deploy"},
+		{"http://jenkins/job/team/job/service/42/", "team/service"},
+		{"https://jenkins/ci/job/service/job/feature%252Flogin/7/", "service/feature%2Flogin"},
+		{"job/team/job/service/", "team/service"},
+		{"/job/my%20job/", "my job"},
+		{"http://jenkins/", ""},
+	}
+	for _, test := range tests {
+		if got := JobNameFromURL(test.url); got != test.want {
+			t.Errorf("JobNameFromURL(%q) = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
+
+func TestJobPathRoundTrip(t *testing.T) {
+	for _, name := range []string{"deploy", "team/service/main", "service/feature%2Flogin", "my job"} {
+		if got := JobNameFromURL("http://jenkins" + jobPath(name) + "/"); got != name {
+			t.Errorf("JobNameFromURL(jobPath(%q)) = %q", name, got)
+		}
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	_, err := client.LastBuild("missing")
+	if !IsNotFound(err) {
+		t.Fatalf("LastBuild of a missing job: got %v, want a 404 StatusError", err)
+	}
+}
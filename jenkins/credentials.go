@@ -0,0 +1,157 @@
+package jenkins
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials authenticate requests to Jenkins.
+type Credentials interface {
+	// Apply adds the credentials to req.
+	Apply(req *http.Request) error
+}
+
+// TokenSource supplies the secret part of credentials. It is asked for the
+// token on every request, so sources that can change, like TokenFile, are
+// picked up without restarting.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// StaticToken is a token that never changes.
+type StaticToken string
+
+// Token returns the token itself.
+func (t StaticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+// BasicAuth sends Username and the token as HTTP Basic credentials, which is
+// how Jenkins API tokens are used.
+type BasicAuth struct {
+	Username string
+	Token    TokenSource
+}
+
+// Apply sets the Authorization header of req.
+func (a BasicAuth) Apply(req *http.Request) error {
+	token, err := a.Token.Token()
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(a.Username, token)
+	return nil
+}
+
+// BearerAuth sends the token as a bearer token, for Jenkins instances behind
+// an OIDC or similar plugin.
+type BearerAuth struct {
+	Token TokenSource
+}
+
+// Apply sets the Authorization header of req.
+func (a BearerAuth) Apply(req *http.Request) error {
+	token, err := a.Token.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// DockerSecretDir is where Docker and Kubernetes mount secrets by default.
+const DockerSecretDir = "/run/secrets"
+
+// TokenFile reads a token from a file such as a Docker secret. The file is
+// read again whenever its modification time or size change, so a rotated
+// token is used from the next request on.
+type TokenFile struct {
+	Path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+// NewTokenFile returns a TokenFile for path and checks that it can be read.
+func NewTokenFile(path string) (*TokenFile, error) {
+	file := &TokenFile{Path: path}
+	if _, err := file.Token(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Token returns the contents of the file with surrounding whitespace removed.
+// When the file can't be read the last token read is kept.
+func (f *TokenFile) Token() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		if f.token != "" {
+			return f.token, nil
+		}
+		return "", fmt.Errorf("reading token: %w", err)
+	}
+	if f.token != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.token, nil
+	}
+
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		if f.token != "" {
+			return f.token, nil
+		}
+		return "", fmt.Errorf("reading token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		if f.token != "" {
+			return f.token, nil
+		}
+		return "", errors.New("token file " + f.Path + " is empty")
+	}
+
+	f.token, f.modTime, f.size = token, info.ModTime(), info.Size()
+	return f.token, nil
+}
+
+// Transport is an http.RoundTripper that adds Credentials to every request
+// to Host before passing it on to Base.
+type Transport struct {
+	Credentials Credentials
+	// Host limits the credentials to requests to this host, e.g.
+	// jenkins:8080, so redirects elsewhere don't carry them. Every request
+	// gets them when it is empty.
+	Host string
+	// Base performs the requests. http.DefaultTransport is used when nil.
+	Base http.RoundTripper
+}
+
+// RoundTrip authenticates a copy of req and sends it.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Credentials == nil || (t.Host != "" && !strings.EqualFold(req.URL.Host, t.Host)) {
+		return base.RoundTrip(req)
+	}
+
+	authenticated := req.Clone(req.Context())
+	if err := t.Credentials.Apply(authenticated); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return base.RoundTrip(authenticated)
+}
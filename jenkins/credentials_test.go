@@ -0,0 +1,131 @@
+package jenkins
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeToken replaces the token file, moving its modification time forward so
+// the change is seen even on file systems with coarse timestamps.
+func writeToken(t *testing.T, path, token string, step int) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(token), 0600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Duration(step) * time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTokenFileRotation(t *testing.T) {
+	basic := func(token string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte("bot:"+token))
+	}
+	bearer := func(token string) string {
+		return "Bearer " + token
+	}
+
+	tests := []struct {
+		name        string
+		credentials func(TokenSource) Credentials
+		header      func(string) string
+	}{
+		{"basic", func(token TokenSource) Credentials { return BasicAuth{Username: "bot", Token: token} }, basic},
+		{"bearer", func(token TokenSource) Credentials { return BearerAuth{Token: token} }, bearer},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "token")
+			writeToken(t, path, "first\n", 0)
+			tokenFile, err := NewTokenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			baseURL := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("Authorization")
+				w.Write([]byte(`{"id": "bot"}`))
+			}).BaseURL
+			client := NewClient(baseURL, test.credentials(tokenFile))
+
+			steps := []struct {
+				name   string
+				change func(step int)
+				want   string
+			}{
+				{"initial token", func(int) {}, "first"},
+				{"rotated token", func(step int) { writeToken(t, path, "second", step) }, "second"},
+				{"empty file keeps the last token", func(step int) { writeToken(t, path, "  \n", step) }, "second"},
+				{"rotated again", func(step int) { writeToken(t, path, "third", step) }, "third"},
+				{"missing file keeps the last token", func(int) { os.Remove(path) }, "third"},
+			}
+			for i, step := range steps {
+				step.change(i)
+				if _, err := client.Me(); err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				if want := test.header(step.want); got != want {
+					t.Errorf("%s: Authorization = %q, want %q", step.name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewTokenFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewTokenFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("NewTokenFile of a missing file succeeded")
+	}
+
+	empty := filepath.Join(dir, "empty")
+	writeToken(t, empty, "\n", 0)
+	if _, err := NewTokenFile(empty); err == nil {
+		t.Error("NewTokenFile of an empty file succeeded")
+	}
+}
+
+func TestCredentialsNotSentToOtherHosts(t *testing.T) {
+	var other string
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other = r.Header.Get("Authorization")
+		w.Write([]byte(`{"id": "someone"}`))
+	}))
+	defer otherServer.Close()
+
+	var own string
+	jenkins := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		own = r.Header.Get("Authorization")
+		http.Redirect(w, r, otherServer.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer jenkins.Close()
+
+	tests := []struct {
+		name        string
+		credentials Credentials
+	}{
+		{"basic", BasicAuth{Username: "bot", Token: StaticToken("secret")}},
+		{"bearer", BearerAuth{Token: StaticToken("secret")}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			own, other = "", ""
+			if _, err := NewClient(jenkins.URL, test.credentials).Me(); err != nil {
+				t.Fatal(err)
+			}
+			if own == "" {
+				t.Error("Jenkins got no Authorization header")
+			}
+			if other != "" {
+				t.Errorf("the redirect target got Authorization %q", other)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package jenkins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Jobs returns the top level jobs of the instance.
+func (c *Client) Jobs() ([]Job, error) {
+	return c.JobTree(0)
+}
+
+// JobTree returns the jobs of the instance with the contents of folders filled
+// in up to depth levels below the top level. The number, result, building flag
+// and timestamp of each job's last build are included, so a whole job list
+// with statuses takes a single request.
+func (c *Client) JobTree(depth int) ([]Job, error) {
+	var data struct {
+		Jobs []Job `json:"jobs"`
+	}
+	if err := c.getJSON("/api/json?tree="+jobTreeQuery(depth), &data); err != nil {
+		return nil, err
+	}
+	return data.Jobs, nil
+}
+
+// jobTreeQuery builds the tree parameter that fetches depth levels of nested jobs.
+func jobTreeQuery(depth int) string {
+	const fields = "_class,name,fullName,url,color,lastBuild[number,url,result,building,timestamp]"
+	query := "jobs[" + fields + "]"
+	for i := 0; i < depth; i++ {
+		query = "jobs[" + fields + "," + query + "]"
+	}
+	return query
+}
+
+// LastBuild returns the most recent build of the job.
+func (c *Client) LastBuild(job string) (*Build, error) {
+	var build Build
+	if err := c.getJSON(jobPath(job)+"/lastBuild/api/json", &build); err != nil {
+		return nil, err
+	}
+	return &build, nil
+}
+
+// Builds returns the newest builds of the job, at most limit of them, newest
+// first.
+func (c *Client) Builds(job string, limit int) ([]Build, error) {
+	var result struct {
+		Builds []Build `json:"builds"`
+	}
+	path := fmt.Sprintf("%s/api/json?tree=builds[number,url,result,building,inProgress,timestamp]{0,%d}", jobPath(job), limit)
+	if err := c.getJSON(path, &result); err != nil {
+		return nil, err
+	}
+	return result.Builds, nil
+}
+
+// Build returns the numbered build of the job.
+func (c *Client) Build(job string, number int) (*Build, error) {
+	var build Build
+	if err := c.getJSON(buildPath(job, number)+"/api/json", &build); err != nil {
+		return nil, err
+	}
+	return &build, nil
+}
+
+// Trigger schedules a build of a job without parameters and returns the ID of
+// the queue item Jenkins created for it.
+func (c *Client) Trigger(job string) (int64, error) {
+	header, err := c.post(jobPath(job) + "/build")
+	if err != nil {
+		return 0, err
+	}
+	return queueIDFromLocation(header.Get("Location")), nil
+}
+
+// TriggerWithParameters schedules a build of a parameterized job. Parameters
+// that are not given fall back to their defaults in Jenkins. The ID of the
+// queue item is returned as for Trigger.
+func (c *Client) TriggerWithParameters(job string, params map[string]string) (int64, error) {
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+
+	path := jobPath(job) + "/buildWithParameters"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	header, err := c.post(path)
+	if err != nil {
+		return 0, err
+	}
+	return queueIDFromLocation(header.Get("Location")), nil
+}
+
+// PendingInputs returns the input steps the build is currently waiting on,
+// with their submitters. Inputs whose submitter Jenkins doesn't report, for
+// instance because its version of the input step plugin doesn't export it,
+// are marked SubmitterUnknown.
+func (c *Client) PendingInputs(job string, number int) ([]PendingInput, error) {
+	var inputs []PendingInput
+	if err := c.getJSON(buildPath(job, number)+"/wfapi/pendingInputActions", &inputs); err != nil {
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		return inputs, nil
+	}
+
+	submitters, err := c.inputSubmitters(job, number)
+	if err != nil {
+		return nil, err
+	}
+	for i := range inputs {
+		submitter, ok := submitters[inputs[i].ID]
+		inputs[i].Submitter = submitter
+		inputs[i].SubmitterUnknown = !ok
+	}
+	return inputs, nil
+}
+
+// inputSubmitters returns the submitter of each input step of a build by
+// input ID. wfapi leaves it out, so it comes from the build's input action.
+func (c *Client) inputSubmitters(job string, number int) (map[string]string, error) {
+	var data struct {
+		Actions []struct {
+			Executions []struct {
+				ID    string `json:"id"`
+				Input struct {
+					Submitter string `json:"submitter"`
+				} `json:"input"`
+			} `json:"executions"`
+		} `json:"actions"`
+	}
+	if err := c.getJSON(buildPath(job, number)+"/api/json?tree=actions[executions[id,input[submitter]]]", &data); err != nil {
+		return nil, err
+	}
+
+	submitters := make(map[string]string)
+	for _, action := range data.Actions {
+		for _, execution := range action.Executions {
+			submitters[execution.ID] = execution.Input.Submitter
+		}
+	}
+	return submitters, nil
+}
+
+// ProceedInput approves a pending input step that takes no parameters.
+func (c *Client) ProceedInput(job string, number int, inputID string) error {
+	_, err := c.post(fmt.Sprintf("%s/input/%s/proceedEmpty", buildPath(job, number), url.PathEscape(inputID)))
+	return err
+}
+
+// ProceedInputWithParameters approves a pending input step that asks for
+// parameters, submitting them the way the input form in Jenkins does.
+// proceedText is the label of the input's proceed button.
+func (c *Client) ProceedInputWithParameters(job string, number int, inputID, proceedText string, params map[string]string) error {
+	type parameter struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	payload := struct {
+		Parameter []parameter `json:"parameter"`
+	}{Parameter: []parameter{}}
+	for name, value := range params {
+		payload.Parameter = append(payload.Parameter, parameter{Name: name, Value: value})
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"json": {string(data)}, "proceed": {proceedText}}
+	return c.postForm(fmt.Sprintf("%s/input/%s/submit", buildPath(job, number), url.PathEscape(inputID)), form)
+}
+
+// AbortInput rejects a pending input step, aborting the build.
+func (c *Client) AbortInput(job string, number int, inputID string) error {
+	_, err := c.post(fmt.Sprintf("%s/input/%s/abort", buildPath(job, number), url.PathEscape(inputID)))
+	return err
+}
+
+// ProgressiveText returns the console output of a build from byte offset
+// start on, the offset to continue from next time and whether Jenkins has
+// more output coming, i.e. the build is still running.
+func (c *Client) ProgressiveText(job string, number int, start int64) (string, int64, bool, error) {
+	resp, err := c.do(http.MethodGet, fmt.Sprintf("%s/logText/progressiveText?start=%d", buildPath(job, number), start), nil)
+	if err != nil {
+		return "", start, false, err
+	}
+	defer resp.Body.Close()
+
+	text, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", start, false, err
+	}
+
+	next := start + int64(len(text))
+	if size, err := strconv.ParseInt(resp.Header.Get("X-Text-Size"), 10, 64); err == nil {
+		next = size
+	}
+	more := resp.Header.Get("X-More-Data") == "true"
+
+	return string(text), next, more, nil
+}
+
+// ConsoleTail returns the end of the console output of a build, at most max
+// bytes of it starting at a line, and whether the output before was left
+// out. The log is streamed, so no more than max bytes of it are held in
+// memory however long it is.
+func (c *Client) ConsoleTail(job string, number int, max int) (string, bool, error) {
+	resp, err := c.do(http.MethodGet, buildPath(job, number)+"/consoleText", nil)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	tail := tailBuffer{buf: make([]byte, max)}
+	if _, err := io.Copy(&tail, resp.Body); err != nil {
+		return "", false, err
+	}
+
+	text := tail.Bytes()
+	truncated := tail.total > int64(max)
+	if truncated {
+		// Don't start in the middle of a line
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+	}
+	return string(text), truncated, nil
+}
+
+// tailBuffer is an io.Writer that keeps the last len(buf) bytes written to it.
+type tailBuffer struct {
+	buf   []byte
+	pos   int
+	total int64
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	t.total += int64(n)
+	if len(t.buf) == 0 {
+		return n, nil
+	}
+	if n >= len(t.buf) {
+		copy(t.buf, p[n-len(t.buf):])
+		t.pos = 0
+		return n, nil
+	}
+
+	copied := copy(t.buf[t.pos:], p)
+	copy(t.buf, p[copied:])
+	t.pos = (t.pos + n) % len(t.buf)
+	return n, nil
+}
+
+// Bytes returns the kept bytes in the order they were written.
+func (t *tailBuffer) Bytes() []byte {
+	if t.total <= int64(len(t.buf)) {
+		return t.buf[:t.total]
+	}
+	return append(append([]byte{}, t.buf[t.pos:]...), t.buf[:t.pos]...)
+}
+
+// Describe returns the stage breakdown of a pipeline run.
+func (c *Client) Describe(job string, number int) (*RunDescription, error) {
+	var description RunDescription
+	if err := c.getJSON(buildPath(job, number)+"/wfapi/describe", &description); err != nil {
+		return nil, err
+	}
+	return &description, nil
+}
+
+// ViewJobs returns the jobs in the named view, with their last builds as in
+// JobTree.
+func (c *Client) ViewJobs(view string, depth int) ([]Job, error) {
+	var data struct {
+		Jobs []Job `json:"jobs"`
+	}
+	if err := c.getJSON("/view/"+url.PathEscape(view)+"/api/json?tree="+jobTreeQuery(depth), &data); err != nil {
+		return nil, err
+	}
+	return data.Jobs, nil
+}
+
+// SetDescription replaces the description of a build.
+func (c *Client) SetDescription(job string, number int, description string) error {
+	query := url.Values{"description": {description}}
+	_, err := c.post(buildPath(job, number) + "/submitDescription?" + query.Encode())
+	return err
+}
+
+// StopBuild asks a running build to stop, like the stop button in Jenkins.
+// Pipelines get the chance to run their post steps.
+func (c *Client) StopBuild(job string, number int) error {
+	_, err := c.post(buildPath(job, number) + "/stop")
+	return err
+}
+
+// TermBuild forcibly terminates a pipeline that did not react to StopBuild.
+func (c *Client) TermBuild(job string, number int) error {
+	_, err := c.post(buildPath(job, number) + "/term")
+	return err
+}
+
+// KillBuild hard kills a pipeline that did not react to TermBuild.
+func (c *Client) KillBuild(job string, number int) error {
+	_, err := c.post(buildPath(job, number) + "/kill")
+	return err
+}
@@ -0,0 +1,200 @@
+package jenkins
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProgressiveText(t *testing.T) {
+	const log = "line 1\nline 2\nline 3\n"
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/job/deploy/3/logText/progressiveText" {
+			http.NotFound(w, r)
+			return
+		}
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		// Jenkins reports the size of the whole log, not of this chunk
+		w.Header().Set("X-Text-Size", strconv.Itoa(len(log)))
+		if start < 7 {
+			w.Header().Set("X-More-Data", "true")
+		}
+		w.Write([]byte(log[start:]))
+	})
+
+	text, next, more, err := client.ProgressiveText("deploy", 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != log || next != int64(len(log)) || !more {
+		t.Errorf("ProgressiveText(0) = %q, %d, %t, want %q, %d, true", text, next, more, log, len(log))
+	}
+
+	text, next, more, err = client.ProgressiveText("deploy", 3, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "line 3\n" || next != int64(len(log)) || more {
+		t.Errorf("ProgressiveText(14) = %q, %d, %t, want %q, %d, false", text, next, more, "line 3\n", len(log))
+	}
+}
+
+func TestProgressiveTextWithoutSize(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("abc"))
+	})
+
+	_, next, more, err := client.ProgressiveText("deploy", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 13 || more {
+		t.Errorf("ProgressiveText without X-Text-Size continues at %d, more %t, want 13, false", next, more)
+	}
+}
+
+func TestProceedInputWithParameters(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/job/team/job/deploy/5/input/Approve/submit" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.PostForm.Get("proceed"); got != "Deploy" {
+			t.Errorf("proceed = %q, want Deploy", got)
+		}
+
+		var payload struct {
+			Parameter []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"parameter"`
+		}
+		if err := json.Unmarshal([]byte(r.PostForm.Get("json")), &payload); err != nil {
+			t.Fatalf("json field %q: %v", r.PostForm.Get("json"), err)
+		}
+		got := make(map[string]string)
+		for _, parameter := range payload.Parameter {
+			got[parameter.Name] = parameter.Value
+		}
+		if len(got) != 2 || got["ENV"] != "prod" || got["NOTE"] != "a&b=c" {
+			t.Errorf("submitted parameters %v", got)
+		}
+	})
+
+	err := client.ProceedInputWithParameters("team/deploy", 5, "Approve", "Deploy", map[string]string{"ENV": "prod", "NOTE": "a&b=c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPendingInputsSubmitters(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/job/deploy/8/wfapi/pendingInputActions":
+			w.Write([]byte(`[{"id": "Approve", "message": "Deploy?"}, {"id": "Open", "message": "Anyone"}, {"id": "Hidden", "message": "Unreported"}]`))
+		case "/job/deploy/8/api/json":
+			w.Write([]byte(`{"actions": [{}, {"executions": [
+				{"id": "Approve", "input": {"submitter": "alice, release-managers"}},
+				{"id": "Open", "input": {}}
+			]}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	inputs, err := client.PendingInputs("deploy", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 3 {
+		t.Fatalf("got %d inputs, want 3", len(inputs))
+	}
+
+	if got := inputs[0].Submitters(); len(got) != 2 || got[0] != "alice" || got[1] != "release-managers" || inputs[0].SubmitterUnknown {
+		t.Errorf("restricted input: submitters %q, unknown %t", got, inputs[0].SubmitterUnknown)
+	}
+	if got := inputs[1].Submitters(); len(got) != 0 || inputs[1].SubmitterUnknown {
+		t.Errorf("unrestricted input: submitters %q, unknown %t", got, inputs[1].SubmitterUnknown)
+	}
+	// An input Jenkins reports no execution for must not look unrestricted
+	if !inputs[2].SubmitterUnknown {
+		t.Errorf("input without an execution entry is not marked SubmitterUnknown")
+	}
+}
+
+func TestPendingInputsWithoutExecutions(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/job/deploy/8/wfapi/pendingInputActions":
+			w.Write([]byte(`[{"id": "Approve", "message": "Deploy?"}]`))
+		case "/job/deploy/8/api/json":
+			w.Write([]byte(`{"actions": [{}, {}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	inputs, err := client.PendingInputs("deploy", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 1 || !inputs[0].SubmitterUnknown {
+		t.Errorf("inputs of a Jenkins that doesn't export executions: %+v", inputs)
+	}
+}
+
+func TestConsoleTail(t *testing.T) {
+	var log strings.Builder
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintf(&log, "line %d\n", i)
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// Write in odd sized chunks so the tail buffer wraps at random places
+		text := log.String()
+		for len(text) > 0 {
+			n := min(len(text), 777)
+			w.Write([]byte(text[:n]))
+			text = text[n:]
+		}
+	})
+
+	tests := []struct {
+		max       int
+		want      string
+		truncated bool
+	}{
+		{100, "line 4992\n", true},
+		{log.Len(), log.String(), false},
+		{log.Len() + 1000, log.String(), false},
+		{10, "", true},
+	}
+	for _, test := range tests {
+		text, truncated, err := client.ConsoleTail("deploy", 1, test.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if truncated != test.truncated || !strings.HasPrefix(text, test.want) || !strings.HasSuffix(log.String(), text) {
+			t.Errorf("ConsoleTail(max %d) = %q, truncated %t", test.max, preview(text), truncated)
+		}
+		if len(text) > test.max {
+			t.Errorf("ConsoleTail(max %d) returned %d bytes", test.max, len(text))
+		}
+	}
+}
+
+// preview shortens s for test failure messages.
+func preview(s string) string {
+	const max = 40
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
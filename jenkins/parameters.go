@@ -0,0 +1,51 @@
+package jenkins
+
+import "fmt"
+
+// Parameter definition types as reported in ParameterDefinition.Type.
+const (
+	StringParameter   = "StringParameterDefinition"
+	TextParameter     = "TextParameterDefinition"
+	ChoiceParameter   = "ChoiceParameterDefinition"
+	BooleanParameter  = "BooleanParameterDefinition"
+	PasswordParameter = "PasswordParameterDefinition"
+)
+
+// ParameterDefinition is a parameter declared by a job's
+// ParametersDefinitionProperty.
+type ParameterDefinition struct {
+	Name                  string          `json:"name"`
+	Type                  string          `json:"type"`
+	Description           string          `json:"description"`
+	Choices               []string        `json:"choices"`
+	DefaultParameterValue *ParameterValue `json:"defaultParameterValue"`
+}
+
+// Default returns the default value of the parameter formatted as Jenkins
+// expects it in a build request, or "" when it has none.
+func (d ParameterDefinition) Default() string {
+	if d.DefaultParameterValue == nil || d.DefaultParameterValue.Value == nil {
+		return ""
+	}
+	return fmt.Sprint(d.DefaultParameterValue.Value)
+}
+
+// ParameterDefinitions returns the parameters a job declares, or nil when the
+// job is not parameterized.
+func (c *Client) ParameterDefinitions(job string) ([]ParameterDefinition, error) {
+	var data struct {
+		Property []struct {
+			ParameterDefinitions []ParameterDefinition `json:"parameterDefinitions"`
+		} `json:"property"`
+	}
+	path := jobPath(job) + "/api/json?tree=property[parameterDefinitions[name,type,description,choices,defaultParameterValue[value]]]"
+	if err := c.getJSON(path, &data); err != nil {
+		return nil, err
+	}
+
+	var definitions []ParameterDefinition
+	for _, property := range data.Property {
+		definitions = append(definitions, property.ParameterDefinitions...)
+	}
+	return definitions, nil
+}
@@ -0,0 +1,58 @@
+package jenkins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QueueItem returns the queue item with the given ID. Jenkins keeps items
+// around for a few minutes after they left the queue, so this is how a
+// triggered build is followed until it has been assigned a build number.
+func (c *Client) QueueItem(id int64) (*QueueItem, error) {
+	var item QueueItem
+	if err := c.getJSON(fmt.Sprintf("/queue/item/%d/api/json", id), &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// queueIDFromLocation extracts the queue item ID from the Location header
+// Jenkins returns when a build is scheduled, e.g. .../queue/item/42/. It
+// returns 0 when the header is missing or not in that form.
+func queueIDFromLocation(location string) int64 {
+	parts := strings.Split(strings.TrimRight(location, "/"), "/")
+	if len(parts) < 3 || parts[len(parts)-3] != "queue" || parts[len(parts)-2] != "item" {
+		return 0
+	}
+
+	id, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// Queue returns the items waiting in the build queue.
+func (c *Client) Queue() ([]QueueItem, error) {
+	var queue struct {
+		Items []QueueItem `json:"items"`
+	}
+	if err := c.getJSON("/queue/api/json", &queue); err != nil {
+		return nil, err
+	}
+	return queue.Items, nil
+}
+
+// CancelQueueItem removes an item from the build queue. Some Jenkins versions
+// answer a successful cancellation with a 404, so a 404 only counts as an
+// error when the item wasn't cancelled.
+func (c *Client) CancelQueueItem(id int64) error {
+	_, err := c.post(fmt.Sprintf("/queue/cancelItem?id=%d", id))
+	if IsNotFound(err) {
+		if item, itemErr := c.QueueItem(id); itemErr == nil && item.Cancelled {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package jenkins
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestQueueIDFromLocation(t *testing.T) {
+	tests := []struct {
+		location string
+		want     int64
+	}{
+		{"http://jenkins/queue/item/42/", 42},
+		{"http://jenkins/queue/item/42", 42},
+		{"/queue/item/7/", 7},
+		{"", 0},
+		{"http://jenkins/job/deploy/", 0},
+		{"http://jenkins/queue/item/abc/", 0},
+		{"http://jenkins/queue/42/", 0},
+	}
+	for _, test := range tests {
+		if got := queueIDFromLocation(test.location); got != test.want {
+			t.Errorf("queueIDFromLocation(%q) = %d, want %d", test.location, got, test.want)
+		}
+	}
+}
+
+func TestTriggerReturnsQueueID(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/job/team/job/deploy/build" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Location", "http://"+r.Host+"/queue/item/99/")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	id, err := client.Trigger("team/deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 99 {
+		t.Errorf("Trigger returned queue ID %d, want 99", id)
+	}
+}
+
+func TestCancelQueueItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		cancelled bool
+		wantErr   bool
+	}{
+		{"ok", http.StatusNoContent, false, false},
+		{"404 after cancelling", http.StatusNotFound, true, false},
+		{"404 without cancelling", http.StatusNotFound, false, true},
+		{"forbidden", http.StatusForbidden, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/queue/cancelItem":
+					if r.Method != http.MethodPost || r.URL.Query().Get("id") != "12" {
+						t.Errorf("unexpected cancel request %s %s", r.Method, r.URL)
+					}
+					w.WriteHeader(test.status)
+				case "/queue/item/12/api/json":
+					fmt.Fprintf(w, `{"id": 12, "cancelled": %t}`, test.cancelled)
+				default:
+					http.NotFound(w, r)
+				}
+			})
+
+			err := client.CancelQueueItem(12)
+			if (err != nil) != test.wantErr {
+				t.Errorf("CancelQueueItem() error = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
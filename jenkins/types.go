@@ -0,0 +1,206 @@
+package jenkins
+
+import (
+	"strings"
+	"time"
+)
+
+// Job is an entry of a Jenkins job listing. Folders, multibranch projects and
+// organization folders are jobs too, with their children in Jobs.
+type Job struct {
+	Class     string `json:"_class"`
+	Name      string `json:"name"`
+	FullName  string `json:"fullName"`
+	URL       string `json:"url"`
+	Color     string `json:"color"`
+	LastBuild *Build `json:"lastBuild"`
+	Jobs      []Job  `json:"jobs"`
+}
+
+// IsFolder reports whether the job contains other jobs rather than builds.
+func (j *Job) IsFolder() bool {
+	if len(j.Jobs) > 0 {
+		return true
+	}
+	switch j.Class {
+	case "com.cloudbees.hudson.plugins.folder.Folder",
+		"org.jenkinsci.plugins.workflow.multibranch.WorkflowMultiBranchProject",
+		"jenkins.branch.OrganizationFolder":
+		return true
+	}
+	return false
+}
+
+// Build is a single run of a job.
+type Build struct {
+	Number            int      `json:"number"`
+	ID                string   `json:"id"`
+	URL               string   `json:"url"`
+	Result            string   `json:"result"`
+	Building          bool     `json:"building"`
+	InProgress        bool     `json:"inProgress"`
+	Duration          int64    `json:"duration"`
+	EstimatedDuration int64    `json:"estimatedDuration"`
+	Timestamp         int64    `json:"timestamp"`
+	Description       string   `json:"description"`
+	Actions           []Action `json:"actions"`
+}
+
+// Running reports whether the build has not finished yet.
+func (b *Build) Running() bool {
+	return b.Building || b.InProgress
+}
+
+// StartTime returns the time the build started.
+func (b *Build) StartTime() time.Time {
+	return time.UnixMilli(b.Timestamp)
+}
+
+// Parameters returns the parameter values the build was started with.
+func (b *Build) Parameters() []ParameterValue {
+	var params []ParameterValue
+	for _, action := range b.Actions {
+		params = append(params, action.Parameters...)
+	}
+	return params
+}
+
+// Action is one of the actions attached to a build. Only the fields the bot
+// uses are decoded.
+type Action struct {
+	Class      string           `json:"_class"`
+	Parameters []ParameterValue `json:"parameters"`
+}
+
+// ParameterValue is the value of a parameter in a build. Value is a string for
+// most parameter types and a bool for boolean parameters.
+type ParameterValue struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// QueueItem is an entry in the Jenkins build queue.
+type QueueItem struct {
+	ID           int64       `json:"id"`
+	Why          string      `json:"why"`
+	Blocked      bool        `json:"blocked"`
+	Buildable    bool        `json:"buildable"`
+	Stuck        bool        `json:"stuck"`
+	Cancelled    bool        `json:"cancelled"`
+	InQueueSince int64       `json:"inQueueSince"`
+	Task         QueueTask   `json:"task"`
+	Executable   *Executable `json:"executable"`
+}
+
+// QueueTask identifies the job a queue item belongs to.
+type QueueTask struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Executable is the build a queue item turned into once it left the queue.
+type Executable struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+}
+
+// PendingInput is an input step a pipeline run is waiting on, as reported by
+// wfapi/pendingInputActions.
+type PendingInput struct {
+	ID                  string           `json:"id"`
+	ProceedText         string           `json:"proceedText"`
+	Message             string           `json:"message"`
+	ProceedURL          string           `json:"proceedUrl"`
+	AbortURL            string           `json:"abortUrl"`
+	RedirectApprovalURL string           `json:"redirectApprovalUrl"`
+	Inputs              []InputParameter `json:"inputs"`
+	// Submitter is the comma separated list of users and groups the input
+	// step lets answer it, or "" when anyone allowed to build the job may.
+	Submitter string `json:"submitter"`
+	// SubmitterUnknown is set when Jenkins didn't report the submitter of the
+	// input step, so it may or may not be restricted.
+	SubmitterUnknown bool `json:"-"`
+}
+
+// Submitters returns the users and groups in Submitter.
+func (input PendingInput) Submitters() []string {
+	var submitters []string
+	for _, submitter := range strings.Split(input.Submitter, ",") {
+		if submitter = strings.TrimSpace(submitter); submitter != "" {
+			submitters = append(submitters, submitter)
+		}
+	}
+	return submitters
+}
+
+// InputParameter is a parameter an input step asks for, as reported in the
+// inputs of wfapi/pendingInputActions.
+type InputParameter struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Definition  struct {
+		DefaultVal interface{} `json:"defaultVal"`
+		Choices    []string    `json:"choices"`
+	} `json:"definition"`
+}
+
+// Parameters returns the parameters of the input step in the form jobs
+// declare theirs, or nil when it takes none.
+func (input PendingInput) Parameters() []ParameterDefinition {
+	var definitions []ParameterDefinition
+	for _, parameter := range input.Inputs {
+		definition := ParameterDefinition{
+			Name:        parameter.Name,
+			Type:        parameter.Type,
+			Description: parameter.Description,
+			Choices:     parameter.Definition.Choices,
+		}
+		if parameter.Definition.DefaultVal != nil {
+			definition.DefaultParameterValue = &ParameterValue{Name: parameter.Name, Value: parameter.Definition.DefaultVal}
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions
+}
+
+// RunDescription is the stage breakdown of a pipeline run, as reported by
+// wfapi/describe.
+type RunDescription struct {
+	ID                  string  `json:"id"`
+	Name                string  `json:"name"`
+	Status              string  `json:"status"`
+	StartTimeMillis     int64   `json:"startTimeMillis"`
+	DurationMillis      int64   `json:"durationMillis"`
+	PauseDurationMillis int64   `json:"pauseDurationMillis"`
+	Stages              []Stage `json:"stages"`
+}
+
+// Stage is a stage of a pipeline run. Status is one of SUCCESS, FAILED,
+// UNSTABLE, ABORTED, IN_PROGRESS, PAUSED_PENDING_INPUT or NOT_EXECUTED.
+type Stage struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	Status              string `json:"status"`
+	StartTimeMillis     int64  `json:"startTimeMillis"`
+	DurationMillis      int64  `json:"durationMillis"`
+	PauseDurationMillis int64  `json:"pauseDurationMillis"`
+}
+
+// Duration returns how long the stage ran.
+func (s *Stage) Duration() time.Duration {
+	return time.Duration(s.DurationMillis) * time.Millisecond
+}
+
+// Authentication is who Jenkins considers the client to be, as reported by
+// whoAmI: the user ID and the groups it is a member of.
+type Authentication struct {
+	Name        string   `json:"name"`
+	Authorities []string `json:"authorities"`
+}
+
+// User is a Jenkins user account.
+type User struct {
+	ID       string `json:"id"`
+	FullName string `json:"fullName"`
+}
@@ -0,0 +1,21 @@
+package jenkins
+
+// Me returns the user the client's credentials belong to. Jenkins answers
+// with a 401 or 403 when the credentials are not accepted.
+func (c *Client) Me() (*User, error) {
+	var user User
+	if err := c.getJSON("/me/api/json", &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// WhoAmI returns the user ID the client's credentials authenticate as, with
+// the groups Jenkins puts it in.
+func (c *Client) WhoAmI() (*Authentication, error) {
+	var authentication Authentication
+	if err := c.getJSON("/whoAmI/api/json", &authentication); err != nil {
+		return nil, err
+	}
+	return &authentication, nil
+}
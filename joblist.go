@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"bot/jenkins"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// jobTreeTTL is how long a fetched job list with statuses is reused.
+	jobTreeTTL = 30 * time.Second
+	// jobListPageSize is the number of lines on a page of the job list.
+	jobListPageSize = 25
+	jobListPrefix   = "joblist"
+	colorJobList    = 0xD33833
+)
+
+// jobTreeCache holds the last job tree fetched from each Jenkins instance.
+type jobTreeCache struct {
+	mu      sync.Mutex
+	entries map[string]jobTreeEntry
+}
+
+type jobTreeEntry struct {
+	jobs    []jenkins.Job
+	fetched time.Time
+}
+
+// jobTree returns the job tree of an instance with last build statuses,
+// fetched in a single request and reused for jobTreeTTL.
+func (bot *Bot) jobTree(instance string) ([]jenkins.Job, error) {
+	client, err := bot.jenkinsInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	// Holding the lock while fetching makes concurrent callers share one request
+	bot.tree.mu.Lock()
+	defer bot.tree.mu.Unlock()
+
+	if entry, ok := bot.tree.entries[instance]; ok && time.Since(entry.fetched) < jobTreeTTL {
+		return entry.jobs, nil
+	}
+
+	jobs, err := client.JobTree(jobTreeDepth)
+	if err != nil {
+		return nil, err
+	}
+	if bot.tree.entries == nil {
+		bot.tree.entries = make(map[string]jobTreeEntry)
+	}
+	bot.tree.entries[instance] = jobTreeEntry{jobs: jobs, fetched: time.Now()}
+	return jobs, nil
+}
+
+// jobListLines renders the job tree as one line per job, indenting the
+// contents of folders below them.
+func jobListLines(jobs []jenkins.Job, depth int) []string {
+	indent := strings.Repeat("\u2003", depth)
+
+	var lines []string
+	for _, job := range jobs {
+		if job.IsFolder() {
+			lines = append(lines, fmt.Sprintf("%s📁 **%s**", indent, job.Name))
+			lines = append(lines, jobListLines(job.Jobs, depth+1)...)
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s%s **%s**", indent, statusEmoji(job.LastBuild), job.Name))
+	}
+	return lines
+}
+
+// paginate splits lines into pages of at most jobListPageSize lines that fit
+// in an embed description.
+func paginate(lines []string) []string {
+	var pages []string
+	var page strings.Builder
+	count := 0
+
+	for _, line := range lines {
+		line = truncate(line, maxEmbedDescription-1)
+		if count == jobListPageSize || page.Len()+len(line)+1 > maxEmbedDescription {
+			pages = append(pages, page.String())
+			page.Reset()
+			count = 0
+		}
+		page.WriteString(line + "\n")
+		count++
+	}
+	if page.Len() > 0 || len(pages) == 0 {
+		pages = append(pages, page.String())
+	}
+	return pages
+}
+
+// jobListMessage renders a page of the job list as an embed, with buttons to
+// move between pages when there is more than one. Without filters the list is
+// the folder tree, otherwise a flat list of the matching jobs.
+func (bot *Bot) jobListMessage(filter listFilter, page int) (*discordgo.MessageSend, error) {
+	client, err := bot.jenkinsInstance(filter.Instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []jenkins.Job
+	if filter.View != "" {
+		jobs, err = client.ViewJobs(filter.View, jobTreeDepth)
+	} else {
+		jobs, err = bot.jobTree(filter.Instance)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	if filter.empty() {
+		lines = jobListLines(jobs, 0)
+	} else {
+		lines = filteredJobListLines(bot.filterJobs(client, jobs, filter), filter)
+	}
+
+	title := "Jenkins Job List"
+	if bot.multipleInstances() {
+		title += fmt.Sprintf(" (%s)", filter.Instance)
+	}
+
+	pages := paginate(lines)
+	if len(lines) == 0 {
+		pages[0] = "No jobs found"
+	}
+	if page < 0 {
+		page = 0
+	}
+	if page >= len(pages) {
+		page = len(pages) - 1
+	}
+
+	message := &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       title,
+			Description: pages[page],
+			Color:       colorJobList,
+			Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d/%d", page+1, len(pages))},
+		}},
+		Components: []discordgo.MessageComponent{},
+	}
+
+	if len(pages) > 1 {
+		message.Components = []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Previous",
+						Style:    discordgo.SecondaryButton,
+						CustomID: listPageButtonID(filter, page-1),
+						Disabled: page == 0,
+					},
+					discordgo.Button{
+						Label:    "Next",
+						Style:    discordgo.SecondaryButton,
+						CustomID: listPageButtonID(filter, page+1),
+						Disabled: page == len(pages)-1,
+					},
+				},
+			},
+		}
+	}
+	return message, nil
+}
+
+// handleJobListPage shows the page of the job list a Previous/Next button
+// points to.
+func (bot *Bot) handleJobListPage(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	filter, page, err := parseListPageButtonID(interaction.MessageComponentData().CustomID)
+	if err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+
+	list, err := bot.jobListMessage(filter, page)
+	if err != nil {
+		bot.respondEphemeral(session, interaction, fmt.Sprintf("Error fetching Jenkins job list: %v", err))
+		return
+	}
+
+	err = session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     list.Embeds,
+			Components: list.Components,
+		},
+	})
+	if err != nil {
+		Logger.Println("Error updating job list page:", err)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"bot/jenkins"
+)
+
+// Statuses !list can filter on.
+const (
+	StatusFailing      = "failing"
+	StatusRunning      = "running"
+	StatusWaitingInput = "waiting-input"
+	StatusNeverBuilt   = "never-built"
+)
+
+// SortRecent sorts the job list by last build time, newest first.
+const SortRecent = "recent"
+
+// maxInputChecks bounds the concurrent pendingInputActions requests made to
+// find builds waiting for input.
+const maxInputChecks = 8
+
+// listFilter narrows down the job list. Name is a glob, or a regular
+// expression when written as /expr/, and is matched against both the job's
+// name and its full name.
+type listFilter struct {
+	Name   string
+	Status string
+	Folder string
+	View   string
+	Sort   string
+	// Instance is the Jenkins instance to list. It is always set by the
+	// time the list is rendered and doesn't count as a filter.
+	Instance string
+}
+
+func (filter listFilter) empty() bool {
+	return filter == listFilter{Instance: filter.Instance}
+}
+
+// parseListFilter parses the key:value arguments of !list, e.g.
+// `status:failing folder:team sort:recent view:"Release Jobs"`.
+func parseListFilter(args string) (listFilter, error) {
+	var filter listFilter
+	for _, token := range splitQuoted(args) {
+		key, value, ok := strings.Cut(token, ":")
+		if !ok || value == "" {
+			return filter, fmt.Errorf("invalid filter %q, expected key:value", token)
+		}
+		switch strings.ToLower(key) {
+		case "name":
+			filter.Name = value
+		case "status":
+			filter.Status = strings.ToLower(value)
+		case "folder":
+			filter.Folder = strings.Trim(value, "/")
+		case "view":
+			filter.View = value
+		case "sort":
+			filter.Sort = strings.ToLower(value)
+		case "instance":
+			filter.Instance = value
+		default:
+			return filter, fmt.Errorf("unknown filter %q, expected name, status, folder, view, sort or instance", key)
+		}
+	}
+	return filter, filter.validate()
+}
+
+// validate checks the values that are restricted to a fixed set.
+func (filter listFilter) validate() error {
+	switch filter.Status {
+	case "", StatusFailing, StatusRunning, StatusWaitingInput, StatusNeverBuilt:
+	default:
+		return fmt.Errorf("unknown status %q, expected %s, %s, %s or %s",
+			filter.Status, StatusFailing, StatusRunning, StatusWaitingInput, StatusNeverBuilt)
+	}
+	switch filter.Sort {
+	case "", "name", SortRecent:
+	default:
+		return fmt.Errorf("unknown sort %q, expected name or %s", filter.Sort, SortRecent)
+	}
+	if pattern, ok := regexPattern(filter.Name); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid name pattern: %v", err)
+		}
+	}
+	return nil
+}
+
+// splitQuoted splits s on whitespace, keeping double quoted parts together.
+func splitQuoted(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// regexPattern returns the expression of a /expr/ name filter.
+func regexPattern(name string) (string, bool) {
+	if len(name) > 1 && strings.HasPrefix(name, "/") && strings.HasSuffix(name, "/") {
+		return name[1 : len(name)-1], true
+	}
+	return "", false
+}
+
+// matchesName reports whether job matches the name filter.
+func (filter listFilter) matchesName(job jenkins.Job) bool {
+	if filter.Name == "" {
+		return true
+	}
+	fullName := jobFullName(job)
+	if pattern, ok := regexPattern(filter.Name); ok {
+		re := regexp.MustCompile(pattern)
+		return re.MatchString(job.Name) || re.MatchString(fullName)
+	}
+	return globMatch(filter.Name, job.Name) || globMatch(filter.Name, fullName)
+}
+
+// matchesStatus reports whether job matches the status filter, apart from
+// waiting-input which needs extra requests and is checked by filterJobs.
+func (filter listFilter) matchesStatus(job jenkins.Job) bool {
+	switch filter.Status {
+	case StatusFailing:
+		// The color reflects the last completed build, even while a new one runs
+		return strings.HasPrefix(job.Color, "red")
+	case StatusRunning, StatusWaitingInput:
+		return job.LastBuild != nil && job.LastBuild.Building
+	case StatusNeverBuilt:
+		return job.LastBuild == nil
+	}
+	return true
+}
+
+// filterJobs returns the jobs of the tree matching filter, flattened and
+// sorted.
+func (bot *Bot) filterJobs(client *jenkins.Client, tree []jenkins.Job, filter listFilter) []jenkins.Job {
+	var jobs []jenkins.Job
+	var walk func([]jenkins.Job)
+	walk = func(level []jenkins.Job) {
+		for _, job := range level {
+			if job.IsFolder() {
+				walk(job.Jobs)
+				continue
+			}
+			if filter.Folder != "" && !strings.HasPrefix(jobFullName(job), filter.Folder+"/") {
+				continue
+			}
+			if filter.matchesName(job) && filter.matchesStatus(job) {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	walk(tree)
+
+	if filter.Status == StatusWaitingInput {
+		jobs = bot.jobsWaitingForInput(client, jobs)
+	}
+
+	if filter.Sort == SortRecent {
+		sort.SliceStable(jobs, func(i, j int) bool {
+			return lastBuildTime(jobs[i]) > lastBuildTime(jobs[j])
+		})
+	} else {
+		sort.SliceStable(jobs, func(i, j int) bool {
+			return strings.ToLower(jobFullName(jobs[i])) < strings.ToLower(jobFullName(jobs[j]))
+		})
+	}
+	return jobs
+}
+
+func lastBuildTime(job jenkins.Job) int64 {
+	if job.LastBuild == nil {
+		return 0
+	}
+	return job.LastBuild.Timestamp
+}
+
+// jobsWaitingForInput returns the jobs whose running last build is paused at
+// an input step, checking up to maxInputChecks builds at a time.
+func (bot *Bot) jobsWaitingForInput(client *jenkins.Client, jobs []jenkins.Job) []jenkins.Job {
+	waiting := make([]bool, len(jobs))
+	semaphore := make(chan struct{}, maxInputChecks)
+	var wg sync.WaitGroup
+
+	for i, job := range jobs {
+		wg.Add(1)
+		go func(i int, job jenkins.Job) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			inputs, err := client.PendingInputs(jobFullName(job), job.LastBuild.Number)
+			if err != nil {
+				Logger.Printf("Error fetching pending inputs of %s: %v\n", jobFullName(job), err)
+				return
+			}
+			waiting[i] = len(inputs) > 0
+		}(i, job)
+	}
+	wg.Wait()
+
+	var result []jenkins.Job
+	for i, job := range jobs {
+		if waiting[i] {
+			result = append(result, job)
+		}
+	}
+	return result
+}
+
+// filteredJobListLines renders the jobs matching filter as a flat list of
+// full names, with the time of the last build when sorting by it.
+func filteredJobListLines(jobs []jenkins.Job, filter listFilter) []string {
+	var lines []string
+	for _, job := range jobs {
+		line := fmt.Sprintf("%s **%s**", statusEmoji(job.LastBuild), jobFullName(job))
+		if filter.Sort == SortRecent && job.LastBuild != nil {
+			line += fmt.Sprintf(" · <t:%d:R>", job.LastBuild.Timestamp/1000)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Filters are carried in the custom ID of the page buttons as a query string
+// so paging keeps working across restarts. Filters too long for a custom ID
+// are kept with storeCustomIDValue and referenced as #<key>.
+
+func (filter listFilter) encode() string {
+	values := url.Values{}
+	for key, value := range map[string]string{"n": filter.Name, "s": filter.Status, "f": filter.Folder, "v": filter.View, "o": filter.Sort, "i": filter.Instance} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values.Encode()
+}
+
+// listPageButtonID returns the custom ID of a button showing page of the job
+// list filtered by filter.
+func listPageButtonID(filter listFilter, page int) string {
+	id := jobListPrefix + "|" + strconv.Itoa(page) + "|" + filter.encode()
+	if len(id) <= maxCustomIDLength {
+		return id
+	}
+
+	return jobListPrefix + "|" + strconv.Itoa(page) + "|#" + storeCustomIDValue(filter)
+}
+
+// parseListPageButtonID is the inverse of listPageButtonID.
+func parseListPageButtonID(customID string) (listFilter, int, error) {
+	parts := strings.SplitN(customID, "|", 3)
+	if len(parts) < 2 {
+		return listFilter{}, 0, fmt.Errorf("malformed job list button: %q", customID)
+	}
+	page, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return listFilter{}, 0, fmt.Errorf("malformed job list button: %q", customID)
+	}
+	if len(parts) == 2 || parts[2] == "" {
+		return listFilter{}, page, nil
+	}
+
+	if key, ok := strings.CutPrefix(parts[2], "#"); ok {
+		value, _ := loadCustomIDValue(key)
+		filter, found := value.(listFilter)
+		if !found {
+			return listFilter{}, 0, fmt.Errorf("this list has expired, run /list again")
+		}
+		return filter, page, nil
+	}
+
+	values, err := url.ParseQuery(parts[2])
+	if err != nil {
+		return listFilter{}, 0, fmt.Errorf("malformed job list button: %q", customID)
+	}
+	return listFilter{
+		Name:     values.Get("n"),
+		Status:   values.Get("s"),
+		Folder:   values.Get("f"),
+		View:     values.Get("v"),
+		Sort:     values.Get("o"),
+		Instance: values.Get("i"),
+	}, page, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// maxMessageLength is Discord's limit on the content of a message.
+	maxMessageLength = 2000
+	logPollInterval  = 5 * time.Second
+	logFlushInterval = 30 * time.Second
+	// logChunkSize leaves room for the code fence around each chunk.
+	logChunkSize = maxMessageLength - len("```\n\n```")
+	// maxLogMessages caps how many messages a single log stream posts so a
+	// runaway build can't flood the thread.
+	maxLogMessages = 100
+	logThreadName  = "Console log of %s #%d"
+)
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// parsePipelineAndBuild splits the arguments of a command into a pipeline name
+// and an optional build number given as the last argument. A build number of
+// 0 means the last build.
+func parsePipelineAndBuild(args []string) (string, int) {
+	if len(args) > 1 {
+		if number, err := strconv.Atoi(strings.TrimPrefix(args[len(args)-1], "#")); err == nil && number > 0 {
+			return strings.Join(args[:len(args)-1], " "), number
+		}
+	}
+	return strings.Join(args, " "), 0
+}
+
+// resolveBuildNumber returns number, or the number of the last build of the
+// pipeline when it is 0.
+func (bot *Bot) resolveBuildNumber(pipelineName string, number int) (int, error) {
+	if number > 0 {
+		return number, nil
+	}
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		return 0, err
+	}
+	build, err := client.LastBuild(job)
+	if err != nil {
+		return 0, err
+	}
+	return build.Number, nil
+}
+
+// startLogThread opens a thread under messageID and streams the console log
+// of the build into it. It blocks until the build finishes.
+func (bot *Bot) startLogThread(channelID, messageID, pipelineName string, number int) {
+	thread, err := bot.Session.MessageThreadStart(channelID, messageID, truncate(fmt.Sprintf(logThreadName, pipelineName, number), 100), 60)
+	if err != nil {
+		Logger.Println("Error starting log thread:", err)
+		bot.Session.ChannelMessageSend(channelID, fmt.Sprintf("Error starting log thread: %v", err))
+		return
+	}
+
+	bot.streamLog(thread.ID, pipelineName, number)
+}
+
+// streamLog follows the console output of a build and posts it to channelID
+// in code blocks until the build finishes.
+func (bot *Bot) streamLog(channelID, pipelineName string, number int) {
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		bot.Session.ChannelMessageSend(channelID, fmt.Sprintf("Error reading console log: %v", err))
+		return
+	}
+
+	var offset int64
+	var pending string
+	sent := 0
+	lastFlush := time.Now()
+
+	for {
+		text, next, more, err := client.ProgressiveText(job, number, offset)
+		if err != nil {
+			Logger.Printf("Error reading console log of %s #%d: %v\n", pipelineName, number, err)
+			bot.Session.ChannelMessageSend(channelID, fmt.Sprintf("Error reading console log: %v", err))
+			return
+		}
+		offset = next
+		pending += ansiEscape.ReplaceAllString(text, "")
+
+		// Batch output until a message is full or a while has passed, and
+		// hold back an incomplete last line while more is coming
+		var chunks []string
+		if !more || len(pending) >= logChunkSize || time.Since(lastFlush) >= logFlushInterval {
+			ready := pending
+			pending = ""
+			if more {
+				if cut := strings.LastIndex(ready, "\n"); cut >= 0 {
+					ready, pending = ready[:cut+1], ready[cut+1:]
+				}
+			}
+			chunks = splitLogChunks(ready, logChunkSize)
+			lastFlush = time.Now()
+		}
+
+		for _, chunk := range chunks {
+			if sent == maxLogMessages {
+				build, _ := client.Build(job, number)
+				link := ""
+				if build != nil {
+					link = "\n" + build.URL + "console"
+				}
+				bot.Session.ChannelMessageSend(channelID, "The log is too long to post in full, read the rest in Jenkins"+link)
+				return
+			}
+			if _, err := bot.Session.ChannelMessageSend(channelID, "```\n"+chunk+"\n```"); err != nil {
+				Logger.Println("Error posting log chunk:", err)
+				return
+			}
+			sent++
+		}
+
+		if !more {
+			bot.Session.ChannelMessageSend(channelID, fmt.Sprintf("End of console log of '%s' #%d", pipelineName, number))
+			return
+		}
+		time.Sleep(logPollInterval)
+	}
+}
+
+// splitLogChunks splits text into pieces of at most size bytes, breaking at
+// line ends where possible. Code fences inside the log are defused so they
+// don't end the code block early.
+func splitLogChunks(text string, size int) []string {
+	text = escapeCodeFences(text)
+
+	var chunks []string
+	for strings.TrimSpace(text) != "" {
+		if len(text) <= size {
+			chunks = append(chunks, strings.TrimRight(text, "\n"))
+			break
+		}
+
+		cut := strings.LastIndex(text[:size], "\n")
+		if cut <= 0 {
+			cut = len(truncate(text, size))
+		}
+		chunks = append(chunks, text[:cut])
+		text = strings.TrimPrefix(text[cut:], "\n")
+	}
+	return chunks
+}
+
+// handleLogsCommand is the /logs slash command.
+func (bot *Bot) handleLogsCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	options := commandOptions(interaction)
+	pipelineName := bot.pipelineOption(interaction)
+	number := 0
+	if option, ok := options["build"]; ok {
+		number = int(option.IntValue())
+	}
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	number, err := bot.resolveBuildNumber(pipelineName, number)
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error fetching build of '%s': %v", pipelineName, err))
+		return
+	}
+
+	msg := bot.editResponse(session, interaction, fmt.Sprintf("📜 Console log of '%s' #%d", pipelineName, number))
+	if msg == nil {
+		return
+	}
+	go bot.startLogThread(interaction.ChannelID, msg.ID, pipelineName, number)
+}
+
+// escapeCodeFences breaks up ``` in text with a zero width space so it can't
+// close the code block it is posted in.
+func escapeCodeFences(text string) string {
+	return strings.ReplaceAll(text, "```", "`\u200b``")
+}
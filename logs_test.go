@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePipelineAndBuild(t *testing.T) {
+	tests := []struct {
+		args  string
+		name  string
+		build int
+	}{
+		{"deploy", "deploy", 0},
+		{"deploy 12", "deploy", 12},
+		{"deploy #12", "deploy", 12},
+		{"my job", "my job", 0},
+		{"my job 12", "my job", 12},
+		{"my job #12", "my job", 12},
+		{"team/service/main 7", "team/service/main", 7},
+		{"deploy 0", "deploy 0", 0},
+		{"deploy -1", "deploy -1", 0},
+		{"deploy #", "deploy #", 0},
+		{"12", "12", 0},
+	}
+	for _, test := range tests {
+		name, build := parsePipelineAndBuild(strings.Fields(test.args))
+		if name != test.name || build != test.build {
+			t.Errorf("parsePipelineAndBuild(%q) = %q, %d, want %q, %d", test.args, name, build, test.name, test.build)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bot/jenkins"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Limits Discord puts on modals and messages.
+const (
+	maxModalInputs     = 5
+	maxSelectMenus     = 4 // one of the five rows holds the Continue button
+	maxSelectOptions   = 25
+	maxModalTitle      = 45
+	maxInputLabel      = 45
+	paramFormPrefix    = "params"
+	paramFormExpiry    = 15 * time.Minute
+	maxInputValueChars = 4000
+)
+
+// paramForm is an interactive /runparams in progress. Choice and boolean
+// parameters are picked from select menus on an ephemeral message, since
+// modals only hold text inputs, and the rest are entered in a modal opened
+// from it.
+type paramForm struct {
+	Pipeline    string
+	UserID      string
+	Definitions []jenkins.ParameterDefinition
+	// Values starts out with the prefilled values and collects the user's input.
+	Values  map[string]string
+	Selects []jenkins.ParameterDefinition
+	Inputs  []jenkins.ParameterDefinition
+	Created time.Time
+
+	// mu guards Values, which select menu and modal interactions may update
+	// concurrently.
+	mu sync.Mutex
+}
+
+func (form *paramForm) set(name, value string) {
+	form.mu.Lock()
+	form.Values[name] = value
+	form.mu.Unlock()
+}
+
+func (form *paramForm) unset(name string) {
+	form.mu.Lock()
+	delete(form.Values, name)
+	form.mu.Unlock()
+}
+
+// snapshot returns a copy of the collected values.
+func (form *paramForm) snapshot() map[string]string {
+	form.mu.Lock()
+	defer form.mu.Unlock()
+	values := make(map[string]string, len(form.Values))
+	for k, v := range form.Values {
+		values[k] = v
+	}
+	return values
+}
+
+var (
+	paramForms      = make(map[string]*paramForm)
+	paramFormsMutex sync.Mutex
+)
+
+func storeParamForm(key string, form *paramForm) {
+	paramFormsMutex.Lock()
+	defer paramFormsMutex.Unlock()
+
+	for k, f := range paramForms {
+		if time.Since(f.Created) > paramFormExpiry {
+			delete(paramForms, k)
+		}
+	}
+	paramForms[key] = form
+}
+
+func loadParamForm(key string) (*paramForm, bool) {
+	paramFormsMutex.Lock()
+	defer paramFormsMutex.Unlock()
+	form, ok := paramForms[key]
+	return form, ok
+}
+
+func deleteParamForm(key string) {
+	paramFormsMutex.Lock()
+	delete(paramForms, key)
+	paramFormsMutex.Unlock()
+}
+
+// lastParameterValues returns the parameter values of the pipeline's last
+// build, or an empty map when it has never run.
+func (bot *Bot) lastParameterValues(pipelineName string) map[string]string {
+	values := make(map[string]string)
+
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		return values
+	}
+
+	build, err := client.LastBuild(job)
+	if err != nil {
+		if !jenkins.IsNotFound(err) {
+			Logger.Printf("Error fetching last build of %s: %v\n", pipelineName, err)
+		}
+		return values
+	}
+
+	for _, parameter := range build.Parameters() {
+		if parameter.Value != nil {
+			values[parameter.Name] = fmt.Sprint(parameter.Value)
+		}
+	}
+	return values
+}
+
+// startParamForm opens the interactive parameter form for /runparams without
+// a parameters option.
+func (bot *Bot) startParamForm(session *discordgo.Session, interaction *discordgo.InteractionCreate, pipelineName string) {
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+
+	definitions, err := client.ParameterDefinitions(job)
+	if err != nil {
+		bot.respondEphemeral(session, interaction, fmt.Sprintf("Error fetching parameter definitions for '%s': %v", pipelineName, err))
+		return
+	}
+	definitions = bot.visibleParameters(definitions)
+	if len(definitions) == 0 {
+		bot.respondEphemeral(session, interaction, fmt.Sprintf("Pipeline '%s' does not take parameters, use /run instead", pipelineName))
+		return
+	}
+
+	form := &paramForm{
+		Pipeline:    pipelineName,
+		UserID:      interactionUser(interaction).ID,
+		Definitions: definitions,
+		Values:      prefillValues(definitions, bot.lastParameterValues(pipelineName)),
+		Created:     time.Now(),
+	}
+
+	// Split the parameters between select menus and modal text inputs. What
+	// doesn't fit keeps its prefilled value.
+	var skipped []string
+	for _, definition := range definitions {
+		switch {
+		case isSelectParameter(definition) && len(form.Selects) < maxSelectMenus:
+			form.Selects = append(form.Selects, definition)
+		case !isSelectParameter(definition) && len(form.Inputs) < maxModalInputs:
+			form.Inputs = append(form.Inputs, definition)
+		default:
+			skipped = append(skipped, definition.Name)
+		}
+	}
+	if len(skipped) > 0 {
+		Logger.Printf("Parameter form for %s leaves %s at their prefilled values\n", pipelineName, strings.Join(skipped, ", "))
+	}
+
+	key := interaction.ID
+	storeParamForm(key, form)
+
+	// Without choices there is nothing to pick, go straight to the modal
+	if len(form.Selects) == 0 {
+		bot.openParamModal(session, interaction, key, form)
+		return
+	}
+
+	content := fmt.Sprintf("Choose the parameters for '%s', then press Continue.", pipelineName)
+	if len(skipped) > 0 {
+		content += fmt.Sprintf("\n%s will keep their previous values, use the `parameters` option to change them.", strings.Join(skipped, ", "))
+	}
+
+	err = session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Flags:      discordgo.MessageFlagsEphemeral,
+			Components: paramSelectComponents(key, form),
+		},
+	})
+	if err != nil {
+		Logger.Println("Error sending parameter form:", err)
+	}
+}
+
+// prefillValues returns the values a form starts with: those of the last
+// build, falling back to the defaults. Passwords are never prefilled, and a
+// last value that is no longer one of the choices would only be rejected.
+func prefillValues(definitions []jenkins.ParameterDefinition, last map[string]string) map[string]string {
+	values := make(map[string]string)
+	for _, definition := range definitions {
+		if definition.Type == jenkins.PasswordParameter {
+			continue
+		}
+		if value, ok := last[definition.Name]; ok && (definition.Type != jenkins.ChoiceParameter || containsString(definition.Choices, value)) {
+			values[definition.Name] = value
+		} else if definition.DefaultParameterValue != nil {
+			values[definition.Name] = definition.Default()
+		}
+	}
+	return values
+}
+
+// isSelectParameter reports whether a parameter is picked from a select menu
+// rather than typed in.
+func isSelectParameter(definition jenkins.ParameterDefinition) bool {
+	switch definition.Type {
+	case jenkins.BooleanParameter:
+		return true
+	case jenkins.ChoiceParameter:
+		if len(definition.Choices) == 0 || len(definition.Choices) > maxSelectOptions {
+			return false
+		}
+		for _, choice := range definition.Choices {
+			if len(choice) > 100 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// paramSelectComponents builds a select menu per choice parameter plus the
+// Continue button.
+func paramSelectComponents(key string, form *paramForm) []discordgo.MessageComponent {
+	var rows []discordgo.MessageComponent
+
+	for i, definition := range form.Selects {
+		choices := definition.Choices
+		if definition.Type == jenkins.BooleanParameter {
+			choices = []string{"true", "false"}
+		}
+
+		var options []discordgo.SelectMenuOption
+		for _, choice := range choices {
+			options = append(options, discordgo.SelectMenuOption{
+				Label:   choice,
+				Value:   choice,
+				Default: form.Values[definition.Name] == choice,
+			})
+		}
+
+		rows = append(rows, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					CustomID:    strings.Join([]string{paramFormPrefix, "select", key, strconv.Itoa(i)}, "|"),
+					Placeholder: truncate(definition.Name, 150),
+					Options:     options,
+				},
+			},
+		})
+	}
+
+	label := "Run"
+	if len(form.Inputs) > 0 {
+		label = "Continue"
+	}
+	rows = append(rows, discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    label,
+				Style:    discordgo.PrimaryButton,
+				CustomID: strings.Join([]string{paramFormPrefix, "continue", key}, "|"),
+			},
+		},
+	})
+	return rows
+}
+
+// openParamModal responds to interaction with the modal holding the text
+// parameters of form.
+func (bot *Bot) openParamModal(session *discordgo.Session, interaction *discordgo.InteractionCreate, key string, form *paramForm) {
+	values := form.snapshot()
+
+	var rows []discordgo.MessageComponent
+	for _, definition := range form.Inputs {
+		style := discordgo.TextInputShort
+		if definition.Type == jenkins.TextParameter {
+			style = discordgo.TextInputParagraph
+		}
+
+		rows = append(rows, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextInput{
+					CustomID:    definition.Name,
+					Label:       truncate(definition.Name, maxInputLabel),
+					Style:       style,
+					Placeholder: truncate(definition.Description, 100),
+					Value:       truncate(values[definition.Name], maxInputValueChars),
+					Required:    false,
+					MaxLength:   maxInputValueChars,
+				},
+			},
+		})
+	}
+
+	err := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID:   strings.Join([]string{paramFormPrefix, "modal", key}, "|"),
+			Title:      truncate("Run "+form.Pipeline, maxModalTitle),
+			Components: rows,
+		},
+	})
+	if err != nil {
+		Logger.Println("Error opening parameter modal:", err)
+	}
+}
+
+// handleParamFormComponent handles the select menus and Continue button of a
+// parameter form.
+func (bot *Bot) handleParamFormComponent(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	data := interaction.MessageComponentData()
+	parts := strings.Split(data.CustomID, "|")
+	if len(parts) < 3 {
+		return
+	}
+	key := parts[2]
+
+	form, ok := loadParamForm(key)
+	if !ok {
+		bot.respondEphemeral(session, interaction, "This form has expired, run /runparams again")
+		return
+	}
+	if form.UserID != interactionUser(interaction).ID {
+		bot.respondEphemeral(session, interaction, "This form belongs to someone else")
+		return
+	}
+
+	switch parts[1] {
+	case "select":
+		index, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil || index >= len(form.Selects) || len(data.Values) == 0 {
+			return
+		}
+		form.set(form.Selects[index].Name, data.Values[0])
+
+		err = session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredMessageUpdate,
+		})
+		if err != nil {
+			Logger.Println("Error acknowledging parameter selection:", err)
+		}
+	case "continue":
+		if len(form.Inputs) > 0 {
+			bot.openParamModal(session, interaction, key, form)
+			return
+		}
+		bot.submitParamForm(session, interaction, key, form)
+	}
+}
+
+// handleParamFormModal collects the text parameters from the submitted modal
+// and triggers the build.
+func (bot *Bot) handleParamFormModal(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	data := interaction.ModalSubmitData()
+	parts := strings.Split(data.CustomID, "|")
+	if len(parts) != 3 {
+		return
+	}
+	key := parts[2]
+
+	form, ok := loadParamForm(key)
+	if !ok {
+		bot.respondEphemeral(session, interaction, "This form has expired, run /runparams again")
+		return
+	}
+
+	passwords := make(map[string]bool)
+	for _, definition := range form.Inputs {
+		passwords[definition.Name] = definition.Type == jenkins.PasswordParameter
+	}
+
+	for _, row := range data.Components {
+		actionsRow, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actionsRow.Components {
+			input, ok := component.(*discordgo.TextInput)
+			if !ok {
+				continue
+			}
+			// A blank password keeps the default Jenkins has for it
+			if input.Value == "" && passwords[input.CustomID] {
+				form.unset(input.CustomID)
+				continue
+			}
+			form.set(input.CustomID, input.Value)
+		}
+	}
+
+	bot.submitParamForm(session, interaction, key, form)
+}
+
+// submitParamForm triggers the pipeline with the values collected by form.
+func (bot *Bot) submitParamForm(session *discordgo.Session, interaction *discordgo.InteractionCreate, key string, form *paramForm) {
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	queueID, err := bot.triggerJenkinsPipelineParams(interactionActor(interaction), form.Pipeline, form.snapshot())
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error triggering Jenkins pipeline '%s': %v", form.Pipeline, err))
+		return
+	}
+	deleteParamForm(key)
+	bot.respondTriggered(session, interaction, form.Pipeline, queueID)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrefillValues(t *testing.T) {
+	tests := []struct {
+		name string
+		last map[string]string
+		want map[string]string
+	}{
+		{
+			name: "defaults without a last build",
+			last: nil,
+			want: map[string]string{"ENV": "staging", "DRY_RUN": "true", "VERSION": "latest"},
+		},
+		{
+			name: "last build's values win, except passwords",
+			last: map[string]string{"ENV": "prod", "VERSION": "1.2", "NOTES": "hi", "SECRET": "hunter2"},
+			want: map[string]string{"ENV": "prod", "DRY_RUN": "true", "VERSION": "1.2", "NOTES": "hi"},
+		},
+		{
+			name: "choice no longer offered falls back to the default",
+			last: map[string]string{"ENV": "qa"},
+			want: map[string]string{"ENV": "staging", "DRY_RUN": "true", "VERSION": "latest"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := prefillValues(testDefinitions, test.last); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("prefillValues() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"bot/jenkins"
+)
+
+// validateParameters checks supplied against the job's parameter definitions
+// and returns the complete set of values to trigger the job with: unknown keys
+// and invalid choices are rejected, booleans are normalised to true/false and
+// parameters that were not supplied get their default.
+func validateParameters(pipelineName string, definitions []jenkins.ParameterDefinition, supplied map[string]string) (map[string]string, error) {
+	if len(definitions) == 0 && len(supplied) > 0 {
+		return nil, fmt.Errorf("pipeline '%s' does not take parameters", pipelineName)
+	}
+
+	byName := make(map[string]jenkins.ParameterDefinition)
+	for _, definition := range definitions {
+		byName[definition.Name] = definition
+	}
+
+	// Report all unknown keys at once, in a stable order
+	var unknown []string
+	for key := range supplied {
+		if _, ok := byName[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown parameter(s) %s for '%s', expected one of: %s",
+			strings.Join(unknown, ", "), pipelineName, strings.Join(parameterNames(definitions), ", "))
+	}
+
+	values := make(map[string]string)
+	for _, definition := range definitions {
+		value, ok := supplied[definition.Name]
+		if !ok {
+			// Jenkins fills in password defaults itself, and the API hides them anyway
+			if definition.Type != jenkins.PasswordParameter && definition.DefaultParameterValue != nil {
+				values[definition.Name] = definition.Default()
+			}
+			continue
+		}
+
+		switch definition.Type {
+		case jenkins.ChoiceParameter:
+			if !containsString(definition.Choices, value) {
+				return nil, fmt.Errorf("invalid value '%s' for %s, expected one of: %s",
+					value, definition.Name, strings.Join(definition.Choices, ", "))
+			}
+		case jenkins.BooleanParameter:
+			coerced, err := parseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for %s: %v", value, definition.Name, err)
+			}
+			value = coerced
+		}
+		values[definition.Name] = value
+	}
+
+	return values, nil
+}
+
+// parseBool accepts the usual spellings of a boolean and returns the form
+// Jenkins expects.
+func parseBool(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "y", "on", "1":
+		return "true", nil
+	case "false", "no", "n", "off", "0":
+		return "false", nil
+	}
+	return "", fmt.Errorf("expected true or false")
+}
+
+// maskParameters returns a copy of values with password parameters hidden,
+// for logging.
+func maskParameters(definitions []jenkins.ParameterDefinition, values map[string]string) map[string]string {
+	masked := make(map[string]string, len(values))
+	for key, value := range values {
+		masked[key] = value
+	}
+	for _, definition := range definitions {
+		if _, ok := masked[definition.Name]; ok && definition.Type == jenkins.PasswordParameter {
+			masked[definition.Name] = "****"
+		}
+	}
+	return masked
+}
+
+// formatParameterValues renders values as key=value pairs in a code span,
+// sorted by key.
+func formatParameterValues(values map[string]string) string {
+	var parameters []string
+	for name, value := range values {
+		parameters = append(parameters, name+"="+value)
+	}
+	sort.Strings(parameters)
+	return "`" + strings.ReplaceAll(strings.Join(parameters, " "), "`", "'") + "`"
+}
+
+// formatParameterSchema describes the parameters of a job with their types,
+// defaults and descriptions.
+func formatParameterSchema(definitions []jenkins.ParameterDefinition) string {
+	if len(definitions) == 0 {
+		return "\nThis pipeline does not take parameters"
+	}
+
+	var buffer strings.Builder
+	for _, definition := range definitions {
+		buffer.WriteString(fmt.Sprintf("\n\n**%s** (%s)", definition.Name, parameterTypeName(definition.Type)))
+
+		switch {
+		case definition.Type == jenkins.ChoiceParameter:
+			buffer.WriteString(fmt.Sprintf("\nChoices: %s", strings.Join(definition.Choices, ", ")))
+		case definition.Type == jenkins.PasswordParameter:
+			// Never show password defaults
+		case definition.DefaultParameterValue != nil:
+			buffer.WriteString(fmt.Sprintf("\nDefault: `%s`", definition.Default()))
+		}
+
+		if definition.Description != "" {
+			buffer.WriteString("\n" + definition.Description)
+		}
+	}
+	return buffer.String()
+}
+
+// parameterTypeName turns a Jenkins parameter class into a short type name.
+func parameterTypeName(definitionType string) string {
+	switch definitionType {
+	case jenkins.StringParameter:
+		return "string"
+	case jenkins.TextParameter:
+		return "text"
+	case jenkins.ChoiceParameter:
+		return "choice"
+	case jenkins.BooleanParameter:
+		return "boolean"
+	case jenkins.PasswordParameter:
+		return "password"
+	}
+	return strings.TrimSuffix(definitionType, "ParameterDefinition")
+}
+
+func parameterNames(definitions []jenkins.ParameterDefinition) []string {
+	names := make([]string, len(definitions))
+	for i, definition := range definitions {
+		names[i] = definition.Name
+	}
+	return names
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate shortens s to at most max bytes without splitting a character.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot/jenkins"
+)
+
+var testDefinitions = []jenkins.ParameterDefinition{
+	{Name: "ENV", Type: jenkins.ChoiceParameter, Choices: []string{"staging", "prod"}, DefaultParameterValue: &jenkins.ParameterValue{Value: "staging"}},
+	{Name: "DRY_RUN", Type: jenkins.BooleanParameter, DefaultParameterValue: &jenkins.ParameterValue{Value: true}},
+	{Name: "VERSION", Type: jenkins.StringParameter, DefaultParameterValue: &jenkins.ParameterValue{Value: "latest"}},
+	{Name: "NOTES", Type: jenkins.TextParameter},
+	{Name: "SECRET", Type: jenkins.PasswordParameter, DefaultParameterValue: &jenkins.ParameterValue{Value: "<hidden>"}},
+}
+
+func TestValidateParameters(t *testing.T) {
+	tests := []struct {
+		name        string
+		definitions []jenkins.ParameterDefinition
+		supplied    map[string]string
+		want        map[string]string
+		wantErr     string
+	}{
+		{
+			name:     "defaults fill in what isn't supplied, except passwords",
+			supplied: nil,
+			want:     map[string]string{"ENV": "staging", "DRY_RUN": "true", "VERSION": "latest"},
+		},
+		{
+			name:     "supplied values win",
+			supplied: map[string]string{"ENV": "prod", "VERSION": "1.2", "NOTES": "a\nb", "SECRET": "hunter2"},
+			want:     map[string]string{"ENV": "prod", "DRY_RUN": "true", "VERSION": "1.2", "NOTES": "a\nb", "SECRET": "hunter2"},
+		},
+		{
+			name:     "booleans are coerced",
+			supplied: map[string]string{"DRY_RUN": "No"},
+			want:     map[string]string{"ENV": "staging", "DRY_RUN": "false", "VERSION": "latest"},
+		},
+		{
+			name:     "invalid boolean",
+			supplied: map[string]string{"DRY_RUN": "maybe"},
+			wantErr:  "invalid value 'maybe' for DRY_RUN",
+		},
+		{
+			name:     "invalid choice",
+			supplied: map[string]string{"ENV": "Prod"},
+			wantErr:  "expected one of: staging, prod",
+		},
+		{
+			name:     "unknown keys are listed sorted",
+			supplied: map[string]string{"ZONE": "a", "APP": "b", "ENV": "prod"},
+			wantErr:  "unknown parameter(s) APP, ZONE for 'deploy'",
+		},
+		{
+			name:        "job without parameters",
+			definitions: []jenkins.ParameterDefinition{},
+			supplied:    map[string]string{"ENV": "prod"},
+			wantErr:     "does not take parameters",
+		},
+		{
+			name:        "job without parameters and nothing supplied",
+			definitions: []jenkins.ParameterDefinition{},
+			want:        map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			definitions := test.definitions
+			if definitions == nil {
+				definitions = testDefinitions
+			}
+
+			got, err := validateParameters("deploy", definitions, test.supplied)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("validateParameters() error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("validateParameters() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"true", "true"}, {"TRUE", "true"}, {" yes ", "true"}, {"y", "true"}, {"on", "true"}, {"1", "true"},
+		{"false", "false"}, {"No", "false"}, {"n", "false"}, {"off", "false"}, {"0", "false"},
+		{"", ""}, {"2", ""}, {"maybe", ""},
+	}
+	for _, test := range tests {
+		got, err := parseBool(test.value)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("parseBool(%q) = %q, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("parseBool(%q) = %q, %v, want %q", test.value, got, err, test.want)
+		}
+	}
+}
+
+func TestMaskParameters(t *testing.T) {
+	values := map[string]string{"ENV": "prod", "SECRET": "hunter2"}
+	masked := maskParameters(testDefinitions, values)
+	if masked["SECRET"] != "****" || masked["ENV"] != "prod" {
+		t.Errorf("maskParameters() = %v", masked)
+	}
+	if values["SECRET"] != "hunter2" {
+		t.Errorf("maskParameters() changed its input")
+	}
+}
+
+func TestParseParameterLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"none", nil, nil, false},
+		{"blank lines only", []string{"", "  "}, nil, false},
+		{"key value", []string{"ENV prod", "", "VERSION 1.2.3"}, map[string]string{"ENV": "prod", "VERSION": "1.2.3"}, false},
+		{"value with spaces", []string{"  NOTES ship it now  "}, map[string]string{"NOTES": "ship it now"}, false},
+		{"repeated key is joined", []string{"TAGS a", "TAGS b"}, map[string]string{"TAGS": "a b"}, false},
+		{"key without value", []string{"ENV"}, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseParameterLines(test.lines)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseParameterLines() error = %v, want error %t", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseParameterLines() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseParameterList(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"pairs", "ENV=prod; VERSION = 1.2 ;", map[string]string{"ENV": "prod", "VERSION": "1.2"}, false},
+		{"value with =", "QUERY=a=b", map[string]string{"QUERY": "a=b"}, false},
+		{"empty value", "NOTES=", map[string]string{"NOTES": ""}, false},
+		{"missing =", "ENV prod", nil, true},
+		{"missing key", "=prod", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseParameterList(test.input)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseParameterList() error = %v, want error %t", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseParameterList() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
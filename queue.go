@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bot/jenkins"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ActionCancel is recorded in the audit log for cancelled queue items, which
+// need the abort permission.
+const ActionCancel = "cancel"
+
+// queuedItem is an item of a build queue with the name commands use for its
+// job.
+type queuedItem struct {
+	Job string
+	jenkins.QueueItem
+}
+
+// fetchQueue returns the queue items of one instance, or of all of them when
+// instance is empty, longest waiting first.
+func (bot *Bot) fetchQueue(instance string) ([]queuedItem, error) {
+	instances := bot.instanceNames
+	if instance != "" {
+		instances = []string{instance}
+	}
+
+	var items []queuedItem
+	for _, name := range instances {
+		client, err := bot.jenkinsInstance(name)
+		if err != nil {
+			return nil, err
+		}
+		queue, err := client.Queue()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range queue {
+			job := jenkins.JobNameFromURL(item.Task.URL)
+			if job == "" {
+				job = item.Task.Name
+			}
+			items = append(items, queuedItem{Job: bot.qualifiedName(name, job), QueueItem: item})
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].InQueueSince < items[j].InQueueSince })
+	return items, nil
+}
+
+// queueEmbed lists queue items with how long they have waited and why.
+func (bot *Bot) queueEmbed(items []queuedItem) *discordgo.MessageEmbed {
+	var lines strings.Builder
+	for _, item := range items {
+		waiting := time.Since(time.UnixMilli(item.InQueueSince)).Round(time.Second)
+		state := "⏳"
+		if item.Stuck {
+			state = "⚠️"
+		} else if item.Blocked {
+			state = "⛔"
+		}
+
+		id := strconv.FormatInt(item.ID, 10)
+		if bot.multipleInstances() {
+			instance, _ := splitInstance(item.Job)
+			id = instance + ":" + id
+		}
+		lines.WriteString(fmt.Sprintf("%s `%s` '%s' waiting %s", state, id, item.Job, waiting))
+		if item.Why != "" {
+			lines.WriteString(": " + truncate(strings.TrimSpace(item.Why), 200))
+		}
+		lines.WriteString("\n")
+	}
+	if len(items) == 0 {
+		lines.WriteString("The queue is empty")
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Build queue (%d)", len(items)),
+		Description: truncate(lines.String(), maxEmbedDescription),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Cancel an item with /cancel <id> or all of a job's with /cancel <job>"},
+	}
+}
+
+// cancelQueued cancels the queue items target names: a queue item ID, which
+// may be prefixed with its instance, or a job whose queued items are all
+// cancelled. Each item needs the abort permission on its job.
+func (bot *Bot) cancelQueued(who actor, target string) (string, error) {
+	instance, rest := splitInstance(target)
+	if instance == "" {
+		instance = bot.channelInstance(who.GuildID, who.ChannelID)
+	}
+
+	var items []queuedItem
+	if id, err := strconv.ParseInt(rest, 10, 64); err == nil {
+		client, err := bot.jenkinsInstance(instance)
+		if err != nil {
+			return "", err
+		}
+		item, err := client.QueueItem(id)
+		if err != nil {
+			return "", fmt.Errorf("Error fetching queue item %d: %v", id, err)
+		}
+		if item.Cancelled || item.Executable != nil {
+			return "", fmt.Errorf("Queue item %d already left the queue", id)
+		}
+		items = append(items, queuedItem{Job: bot.qualifiedName(instance, jenkins.JobNameFromURL(item.Task.URL)), QueueItem: *item})
+	} else {
+		job := bot.qualifyJob(target, who.GuildID, who.ChannelID)
+		queue, err := bot.fetchQueue(instance)
+		if err != nil {
+			return "", fmt.Errorf("Error fetching the build queue: %v", err)
+		}
+		for _, item := range queue {
+			if item.Job == job {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			return "", fmt.Errorf("Jenkins pipeline '%s' has nothing in the queue", job)
+		}
+	}
+
+	var cancelled []string
+	for _, item := range items {
+		if err := bot.authorize(who, ActionAbort, item.Job); err != nil {
+			return strings.Join(cancelled, "\n"), err
+		}
+
+		client, _, err := bot.jenkinsJobAs(who, ActionAbort, item.Job)
+		if err == nil {
+			err = client.CancelQueueItem(item.ID)
+		}
+		bot.audit(who, auditEntry{Action: ActionCancel, Job: item.Job, QueueID: item.ID}, err)
+		if err != nil {
+			return strings.Join(cancelled, "\n"), fmt.Errorf("Error cancelling queue item %d of '%s': %v", item.ID, item.Job, err)
+		}
+		Logger.Printf("Cancelled queue item %d of %s for %s (%s)\n", item.ID, item.Job, who.User.Username, who.User.ID)
+		cancelled = append(cancelled, fmt.Sprintf("🛑 Cancelled queue item %d of '%s'", item.ID, item.Job))
+	}
+	return strings.Join(cancelled, "\n"), nil
+}
+
+// handleQueueCommand is the /queue slash command.
+func (bot *Bot) handleQueueCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	items, err := bot.fetchQueue("")
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error fetching the build queue: %v", err))
+		return
+	}
+
+	_, err = session.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{bot.queueEmbed(items)},
+	})
+	if err != nil {
+		Logger.Println("Error editing interaction response:", err)
+	}
+}
+
+// handleCancelCommand is the /cancel slash command.
+func (bot *Bot) handleCancelCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	target := strings.TrimSpace(commandOptions(interaction)["target"].StringValue())
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	cancelled, err := bot.cancelQueued(interactionActor(interaction), target)
+	if err != nil {
+		cancelled = strings.TrimSpace(cancelled + "\n" + err.Error())
+	}
+	bot.editResponse(session, interaction, cancelled)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"bot/jenkins"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	stagePollInterval = 10 * time.Second
+	colorSuccess      = 0x3FB950
+	colorRunning      = 0x3B82F6
+	colorOther        = 0x8B949E
+)
+
+// stageEmoji maps a wfapi stage or run status to an emoji.
+func stageEmoji(status string) string {
+	switch status {
+	case "SUCCESS":
+		return "<:jenkinsgreencheck:1192251531811094588>"
+	case "FAILED":
+		return "<:jenkinsfail:1192276960399851641>"
+	case "IN_PROGRESS":
+		return "<a:jenkinsrunning:1194478025975279687>"
+	case "PAUSED_PENDING_INPUT":
+		return "⏸️"
+	case "UNSTABLE":
+		return "⚠️"
+	case "ABORTED":
+		return "🛑"
+	default:
+		return "<:jenkinsnotrun:1254459002167885988>"
+	}
+}
+
+// runInProgress reports whether a run described by wfapi has not finished.
+func runInProgress(description *jenkins.RunDescription) bool {
+	return description.Status == "IN_PROGRESS" || description.Status == "PAUSED_PENDING_INPUT"
+}
+
+// stagesEmbed renders the stages of a run with their status, duration and
+// time spent paused.
+func stagesEmbed(pipelineName, buildURL string, description *jenkins.RunDescription) *discordgo.MessageEmbed {
+	var lines strings.Builder
+	for _, stage := range description.Stages {
+		lines.WriteString(fmt.Sprintf("%s **%s** %s", stageEmoji(stage.Status), stage.Name, stage.Duration().Round(time.Second)))
+		if stage.PauseDurationMillis > 0 {
+			paused := (time.Duration(stage.PauseDurationMillis) * time.Millisecond).Round(time.Second)
+			lines.WriteString(fmt.Sprintf(" (paused %s)", paused))
+		}
+		lines.WriteString("\n")
+	}
+	if len(description.Stages) == 0 {
+		lines.WriteString("No stages yet")
+	}
+
+	color := colorOther
+	switch description.Status {
+	case "SUCCESS":
+		color = colorSuccess
+	case "FAILED":
+		color = colorFailure
+	case "IN_PROGRESS", "PAUSED_PENDING_INPUT":
+		color = colorRunning
+	}
+
+	duration := (time.Duration(description.DurationMillis) * time.Millisecond).Round(time.Second)
+	embed := &discordgo.MessageEmbed{
+		Title:       truncate(fmt.Sprintf("%s '%s' #%s", stageEmoji(description.Status), pipelineName, description.ID), 256),
+		URL:         buildURL,
+		Description: truncate(lines.String(), maxEmbedDescription),
+		Color:       color,
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("%s after %s", description.Status, duration)},
+	}
+	if runInProgress(description) {
+		embed.Footer.Text += " · updating live"
+	}
+	return embed
+}
+
+// fetchStagesEmbed renders the stage view of a build.
+func (bot *Bot) fetchStagesEmbed(pipelineName string, number int) (*discordgo.MessageEmbed, bool, error) {
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	description, err := client.Describe(job, number)
+	if err != nil {
+		return nil, false, err
+	}
+
+	buildURL := ""
+	if build, err := client.Build(job, number); err == nil {
+		buildURL = build.URL
+	}
+	return stagesEmbed(pipelineName, buildURL, description), runInProgress(description), nil
+}
+
+// watchStages keeps the stage view in messageID up to date until the build
+// finishes. It is meant to be run in its own goroutine.
+func (bot *Bot) watchStages(channelID, messageID, pipelineName string, number int) {
+	deadline := time.Now().Add(trackTimeout)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(stagePollInterval)
+
+		embed, running, err := bot.fetchStagesEmbed(pipelineName, number)
+		if err != nil {
+			Logger.Printf("Error fetching stages of %s #%d: %v\n", pipelineName, number, err)
+			continue
+		}
+		if _, err := bot.Session.ChannelMessageEditEmbed(channelID, messageID, embed); err != nil {
+			Logger.Println("Error updating stage view:", err)
+			return
+		}
+		if !running {
+			return
+		}
+	}
+}
+
+// handleStagesCommand is the /stages slash command.
+func (bot *Bot) handleStagesCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	options := commandOptions(interaction)
+	pipelineName := bot.pipelineOption(interaction)
+	number := 0
+	if option, ok := options["build"]; ok {
+		number = int(option.IntValue())
+	}
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	number, err := bot.resolveBuildNumber(pipelineName, number)
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error fetching build of '%s': %v", pipelineName, err))
+		return
+	}
+
+	embed, running, err := bot.fetchStagesEmbed(pipelineName, number)
+	if err != nil {
+		bot.editResponse(session, interaction, fmt.Sprintf("Error fetching stages of '%s' #%d: %v", pipelineName, number, err))
+		return
+	}
+
+	msg, err := session.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+	if err != nil {
+		Logger.Println("Error editing interaction response:", err)
+		return
+	}
+	if running {
+		go bot.watchStages(interaction.ChannelID, msg.ID, pipelineName, number)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bot/jenkins"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ActionStop is recorded in the audit log for stopped builds, which need the
+// abort permission.
+const ActionStop = "stop"
+
+const (
+	defaultTermAfter = 30 * time.Second
+	defaultKillAfter = 60 * time.Second
+	stopPollInterval = 5 * time.Second
+)
+
+// StopConfig sets how long a build gets to stop before it is terminated, and
+// how long after that before it is killed, as durations such as "30s".
+type StopConfig struct {
+	TermAfter string `json:"termAfter"`
+	KillAfter string `json:"killAfter"`
+}
+
+// timeouts returns the configured escalation timeouts, falling back to the
+// defaults for those that aren't set.
+func (config StopConfig) timeouts() (time.Duration, time.Duration, error) {
+	term, kill := defaultTermAfter, defaultKillAfter
+	var err error
+	if config.TermAfter != "" {
+		if term, err = time.ParseDuration(config.TermAfter); err != nil {
+			return 0, 0, fmt.Errorf("stop.termAfter: %w", err)
+		}
+	}
+	if config.KillAfter != "" {
+		if kill, err = time.ParseDuration(config.KillAfter); err != nil {
+			return 0, 0, fmt.Errorf("stop.killAfter: %w", err)
+		}
+	}
+	return term, kill, nil
+}
+
+// stopRef identifies the build a stop confirmation is about.
+type stopRef struct {
+	Job   string
+	Build int
+}
+
+// Custom IDs of stop confirmation buttons have the form
+// "stop|<action>|<build>|<job>", falling back to "stop|<action>|#<key>" like
+// input buttons when the job name is too long.
+const stopButtonPrefix = "stop"
+
+// stopButtonID encodes a confirmation choice into a button custom ID.
+func stopButtonID(action string, ref stopRef) string {
+	id := strings.Join([]string{stopButtonPrefix, action, strconv.Itoa(ref.Build), ref.Job}, "|")
+	if len(id) <= maxCustomIDLength {
+		return id
+	}
+
+	return strings.Join([]string{stopButtonPrefix, action, "#" + storeCustomIDValue(ref)}, "|")
+}
+
+// parseStopButtonID is the inverse of stopButtonID.
+func parseStopButtonID(customID string) (string, stopRef, error) {
+	parts := strings.SplitN(customID, "|", 4)
+	if len(parts) < 3 || parts[0] != stopButtonPrefix {
+		return "", stopRef{}, fmt.Errorf("not a stop button: %q", customID)
+	}
+	action := parts[1]
+
+	if key, ok := strings.CutPrefix(parts[2], "#"); ok {
+		value, _ := loadCustomIDValue(key)
+		ref, found := value.(stopRef)
+		if !found {
+			return "", stopRef{}, fmt.Errorf("this button has expired, run /stop again")
+		}
+		return action, ref, nil
+	}
+
+	if len(parts) != 4 {
+		return "", stopRef{}, fmt.Errorf("malformed stop button: %q", customID)
+	}
+	build, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", stopRef{}, fmt.Errorf("malformed build number in %q", customID)
+	}
+	return action, stopRef{Job: parts[3], Build: build}, nil
+}
+
+// stoppingBuilds holds the builds a confirmed stop is escalating on, so that
+// confirming again, from the same message or another one, doesn't start a
+// second escalation.
+var (
+	stoppingBuilds      = make(map[stopRef]bool)
+	stoppingBuildsMutex sync.Mutex
+)
+
+// startStopping records that ref is being stopped. It returns false when it
+// already is.
+func startStopping(ref stopRef) bool {
+	stoppingBuildsMutex.Lock()
+	defer stoppingBuildsMutex.Unlock()
+
+	if stoppingBuilds[ref] {
+		return false
+	}
+	stoppingBuilds[ref] = true
+	return true
+}
+
+// doneStopping forgets that ref is being stopped.
+func doneStopping(ref stopRef) {
+	stoppingBuildsMutex.Lock()
+	defer stoppingBuildsMutex.Unlock()
+
+	delete(stoppingBuilds, ref)
+}
+
+// stopConfirmation asks to confirm stopping a build, or returns an error
+// when the build is not running.
+func (bot *Bot) stopConfirmation(pipelineName string, number int) (string, []discordgo.MessageComponent, error) {
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	number, err = bot.resolveBuildNumber(pipelineName, number)
+	if err != nil {
+		return "", nil, fmt.Errorf("Error fetching build of '%s': %v", pipelineName, err)
+	}
+	build, err := client.Build(job, number)
+	if err != nil {
+		return "", nil, fmt.Errorf("Error fetching '%s' #%d: %v", pipelineName, number, err)
+	}
+	if !build.Running() {
+		return "", nil, fmt.Errorf("Jenkins pipeline '%s' #%d is not running, it finished: **%s**", pipelineName, number, build.Result)
+	}
+
+	ref := stopRef{Job: pipelineName, Build: number}
+	content := fmt.Sprintf("🛑 Stop Jenkins pipeline '%s' #%d, running since <t:%d:R>?\n%s",
+		pipelineName, number, build.StartTime().Unix(), build.URL)
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Stop build",
+					Style:    discordgo.DangerButton,
+					CustomID: stopButtonID("confirm", ref),
+				},
+				discordgo.Button{
+					Label:    "Keep running",
+					Style:    discordgo.SecondaryButton,
+					CustomID: stopButtonID("dismiss", ref),
+				},
+			},
+		},
+	}
+	return content, components, nil
+}
+
+// stopBuild stops a running build, escalating to terminating and then killing
+// it when it keeps running past the configured timeouts. It returns the
+// finished build and the last step it took.
+func (bot *Bot) stopBuild(who actor, pipelineName string, number int) (*jenkins.Build, string, error) {
+	client, job, err := bot.jenkinsJobAs(who, ActionAbort, pipelineName)
+	if err != nil {
+		return nil, "", err
+	}
+	termAfter, killAfter, err := bot.Config.Stop.timeouts()
+	if err != nil {
+		return nil, "", err
+	}
+
+	steps := []struct {
+		name  string
+		call  func(string, int) error
+		grace time.Duration
+	}{
+		{"stop", client.StopBuild, termAfter},
+		{"term", client.TermBuild, killAfter},
+		{"kill", client.KillBuild, killAfter},
+	}
+
+	for _, step := range steps {
+		Logger.Printf("Sending %s to %s #%d for %s (%s)\n", step.name, pipelineName, number, who.User.Username, who.User.ID)
+		if err := step.call(job, number); err != nil {
+			return nil, step.name, fmt.Errorf("%s failed: %w", step.name, err)
+		}
+
+		deadline := time.Now().Add(step.grace)
+		for time.Now().Before(deadline) {
+			time.Sleep(stopPollInterval)
+			build, err := client.Build(job, number)
+			if err != nil {
+				Logger.Printf("Error fetching %s #%d: %v\n", pipelineName, number, err)
+				continue
+			}
+			if !build.Running() {
+				return build, step.name, nil
+			}
+		}
+	}
+	return nil, "kill", fmt.Errorf("the build is still running after being killed")
+}
+
+// handleStopButton answers a stop confirmation. Confirming stops the build
+// and reports how it ended in the confirmation message. Confirming a build
+// that is already being stopped is ignored.
+func (bot *Bot) handleStopButton(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	action, ref, err := parseStopButtonID(interaction.MessageComponentData().CustomID)
+	if err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+	who := interactionActor(interaction)
+	if err := bot.authorize(who, ActionAbort, ref.Job); err != nil {
+		bot.respondEphemeral(session, interaction, err.Error())
+		return
+	}
+
+	content := fmt.Sprintf("🛑 Stopping Jenkins pipeline '%s' #%d, requested by %s", ref.Job, ref.Build, who.User.Mention())
+	if action == "dismiss" {
+		content = fmt.Sprintf("Jenkins pipeline '%s' #%d was left running by %s", ref.Job, ref.Build, who.User.Mention())
+	} else {
+		if !startStopping(ref) {
+			bot.respondEphemeral(session, interaction, fmt.Sprintf("Jenkins pipeline '%s' #%d is already being stopped", ref.Job, ref.Build))
+			return
+		}
+		defer doneStopping(ref)
+	}
+	err = session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		Logger.Println("Error updating stop confirmation:", err)
+		return
+	}
+	if action == "dismiss" {
+		return
+	}
+
+	build, step, err := bot.stopBuild(who, ref.Job, ref.Build)
+	bot.audit(who, auditEntry{Action: ActionStop, Job: ref.Job, Build: ref.Build}, err)
+	if err != nil {
+		Logger.Printf("Error stopping %s #%d: %v\n", ref.Job, ref.Build, err)
+		content = fmt.Sprintf("Error stopping Jenkins pipeline '%s' #%d: %v", ref.Job, ref.Build, err)
+	} else {
+		duration := (time.Duration(build.Duration) * time.Millisecond).Round(time.Second)
+		content = fmt.Sprintf("%s Jenkins pipeline '%s' #%d stopped by %s with %s: **%s** after %s\n%s",
+			statusEmoji(build), ref.Job, ref.Build, who.User.Mention(), step, build.Result, duration, build.URL)
+	}
+	// Escalating can outlast the interaction token, so edit the message itself
+	if _, err := session.ChannelMessageEdit(interaction.ChannelID, interaction.Message.ID, content); err != nil {
+		Logger.Println("Error reporting stopped build:", err)
+	}
+}
+
+// handleStopCommand is the /stop slash command.
+func (bot *Bot) handleStopCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	options := commandOptions(interaction)
+	pipelineName := bot.pipelineOption(interaction)
+	number := 0
+	if option, ok := options["build"]; ok {
+		number = int(option.IntValue())
+	}
+	if !bot.deferResponse(session, interaction) {
+		return
+	}
+
+	content, components, err := bot.stopConfirmation(pipelineName, number)
+	if err != nil {
+		bot.editResponse(session, interaction, err.Error())
+		return
+	}
+	_, err = session.InteractionResponseEdit(interaction.Interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &components,
+	})
+	if err != nil {
+		Logger.Println("Error editing interaction response:", err)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestStartStopping(t *testing.T) {
+	ref := stopRef{Job: "deploy", Build: 7}
+	if !startStopping(ref) {
+		t.Fatal("startStopping() = false for a build not being stopped")
+	}
+	if startStopping(ref) {
+		t.Error("startStopping() = true for a build already being stopped")
+	}
+	if !startStopping(stopRef{Job: "deploy", Build: 8}) {
+		t.Error("startStopping() = false for another build")
+	}
+
+	doneStopping(ref)
+	if !startStopping(ref) {
+		t.Error("startStopping() = false after doneStopping()")
+	}
+}
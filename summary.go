@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"bot/jenkins"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// failureLogLines is how much of the end of the console log a failure
+	// summary shows.
+	failureLogLines = 30
+	// maxEmbedDescription is Discord's limit on an embed description.
+	maxEmbedDescription = 4096
+	// maxAttachmentSize keeps attached logs below Discord's upload limit.
+	maxAttachmentSize = 8 << 20
+	colorFailure      = 0xD93F3F
+)
+
+// postFailureSummary posts an embed describing why a build failed: the stage
+// that failed, how long it ran and the end of the console log, with the full
+// log attached when it is too long for a message. Logs over the attachment
+// limit are cut from the front. When messageID is set the summary is posted
+// as a reply to it.
+func (bot *Bot) postFailureSummary(channelID, messageID, pipelineName string, build *jenkins.Build) {
+	embed := &discordgo.MessageEmbed{
+		Title: truncate(fmt.Sprintf("'%s' #%d failed", pipelineName, build.Number), 256),
+		URL:   build.URL,
+		Color: colorFailure,
+	}
+
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		Logger.Println("Error posting failure summary:", err)
+		return
+	}
+
+	description, err := client.Describe(job, build.Number)
+	if err != nil {
+		Logger.Printf("Error fetching stages of %s #%d: %v\n", pipelineName, build.Number, err)
+	} else if stage := failedStage(description.Stages); stage != nil {
+		embed.Fields = append(embed.Fields,
+			&discordgo.MessageEmbedField{Name: "Failed stage", Value: truncate(stage.Name, 1024), Inline: true},
+			&discordgo.MessageEmbedField{Name: "Stage duration", Value: stage.Duration().Round(time.Second).String(), Inline: true},
+		)
+	}
+
+	message := &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}}
+	if messageID != "" {
+		message.Reference = &discordgo.MessageReference{MessageID: messageID, ChannelID: channelID}
+	}
+
+	// Only the end of the log is shown or attached, so don't fetch more
+	consoleLog, _, err := client.ConsoleTail(job, build.Number, maxAttachmentSize)
+	if err != nil {
+		Logger.Printf("Error fetching console log of %s #%d: %v\n", pipelineName, build.Number, err)
+	} else {
+		consoleLog = ansiEscape.ReplaceAllString(consoleLog, "")
+		embed.Description = logExcerpt(consoleLog, failureLogLines, maxEmbedDescription)
+
+		if len(consoleLog) > maxMessageLength {
+			message.Files = []*discordgo.File{{
+				Name:        fmt.Sprintf("%s-%d.log", strings.ReplaceAll(pipelineName, "/", "-"), build.Number),
+				ContentType: "text/plain",
+				Reader:      bytes.NewReader([]byte(consoleLog)),
+			}}
+		}
+	}
+
+	if _, err := bot.Session.ChannelMessageSendComplex(channelID, message); err != nil {
+		Logger.Println("Error posting failure summary:", err)
+	}
+}
+
+// failedStage returns the stage a run failed in: the first FAILED stage, or
+// failing that the last stage that did not succeed.
+func failedStage(stages []jenkins.Stage) *jenkins.Stage {
+	var last *jenkins.Stage
+	for i := range stages {
+		switch stages[i].Status {
+		case "FAILED":
+			return &stages[i]
+		case "SUCCESS", "NOT_EXECUTED":
+		default:
+			last = &stages[i]
+		}
+	}
+	return last
+}
+
+// logExcerpt returns the last lines of log as a code block of at most max
+// bytes, dropping lines from the front until it fits.
+func logExcerpt(log string, lines, max int) string {
+	all := strings.Split(strings.TrimRight(log, "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+
+	for {
+		block := "```\n" + escapeCodeFences(strings.Join(all, "\n")) + "\n```"
+		if len(block) <= max || len(all) == 1 {
+			return truncate(block, max)
+		}
+		all = all[1:]
+	}
+}
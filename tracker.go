@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"bot/jenkins"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	queuePollInterval = 5 * time.Second
+	buildPollInterval = 15 * time.Second
+	trackTimeout      = 24 * time.Hour
+)
+
+// trackBuild follows a build who triggered from its queue item until it
+// finishes, editing the trigger message once the build starts and replying to
+// it with the result. It is meant to be run in its own goroutine.
+func (bot *Bot) trackBuild(who actor, messageID, pipelineName string, queueID int64) {
+	channelID := who.ChannelID
+	if queueID == 0 {
+		Logger.Printf("No queue item returned for %s, not tracking build\n", pipelineName)
+		return
+	}
+
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		Logger.Println("Error tracking build:", err)
+		return
+	}
+
+	deadline := time.Now().Add(trackTimeout)
+
+	// Wait for the queue item to be assigned a build
+	var executable *jenkins.Executable
+	for executable == nil {
+		if time.Now().After(deadline) {
+			Logger.Printf("Gave up waiting for queue item %d of %s\n", queueID, pipelineName)
+			return
+		}
+
+		item, err := client.QueueItem(queueID)
+		if err != nil {
+			Logger.Printf("Error fetching queue item %d of %s: %v\n", queueID, pipelineName, err)
+			if jenkins.IsNotFound(err) {
+				bot.Session.ChannelMessageEdit(channelID, messageID, fmt.Sprintf("Jenkins pipeline '%s' left the queue before a build started, its queue item was cancelled or expired", pipelineName))
+				return
+			}
+		} else if item.Cancelled {
+			bot.Session.ChannelMessageEdit(channelID, messageID, fmt.Sprintf("Jenkins pipeline '%s' was cancelled while in the queue", pipelineName))
+			return
+		} else {
+			executable = item.Executable
+		}
+
+		if executable == nil {
+			time.Sleep(queuePollInterval)
+		}
+	}
+
+	bot.Session.ChannelMessageEdit(channelID, messageID,
+		fmt.Sprintf("<a:jenkinsrunning:1194478025975279687> Jenkins pipeline '%s' build #%d started by %s\n%s", pipelineName, executable.Number, who.User.Mention(), executable.URL))
+	bot.describeTrigger(who, pipelineName, executable.Number, messageID)
+
+	// Poll the build until it is done, offering buttons for any input step it stops at
+	announced := make(map[string]bool)
+	for {
+		if time.Now().After(deadline) {
+			Logger.Printf("Gave up waiting for %s #%d to finish\n", pipelineName, executable.Number)
+			return
+		}
+
+		build, err := client.Build(job, executable.Number)
+		if err != nil {
+			Logger.Printf("Error fetching %s #%d: %v\n", pipelineName, executable.Number, err)
+		} else if !build.Running() {
+			bot.postBuildResult(who, messageID, pipelineName, build)
+			return
+		} else {
+			bot.announcePendingInputs(channelID, pipelineName, build.Number, announced)
+		}
+
+		time.Sleep(buildPollInterval)
+	}
+}
+
+// postBuildResult updates the trigger message with the outcome of a finished
+// build and replies to it so the channel is notified.
+func (bot *Bot) postBuildResult(who actor, messageID, pipelineName string, build *jenkins.Build) {
+	channelID := who.ChannelID
+	duration := (time.Duration(build.Duration) * time.Millisecond).Round(time.Second)
+	content := fmt.Sprintf("%s Jenkins pipeline '%s' build #%d started by %s finished: **%s** in %s\n%s",
+		statusEmoji(build), pipelineName, build.Number, who.User.Mention(), build.Result, duration, build.URL)
+
+	bot.Session.ChannelMessageEdit(channelID, messageID, content)
+
+	_, err := bot.Session.ChannelMessageSendReply(channelID,
+		fmt.Sprintf("%s '%s' #%d: **%s**", statusEmoji(build), pipelineName, build.Number, build.Result),
+		&discordgo.MessageReference{MessageID: messageID, ChannelID: channelID})
+	if err != nil {
+		Logger.Println("Error replying with build result:", err)
+	}
+
+	if build.Result == "FAILURE" {
+		bot.postFailureSummary(channelID, messageID, pipelineName, build)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+
+	"bot/jenkins"
+)
+
+// discordLink returns a link to a channel, or to a message in it when
+// messageID is set. DMs have no guild and use @me instead.
+func discordLink(guildID, channelID, messageID string) string {
+	if guildID == "" {
+		guildID = "@me"
+	}
+	link := fmt.Sprintf("https://discord.com/channels/%s/%s", guildID, channelID)
+	if messageID != "" {
+		link += "/" + messageID
+	}
+	return link
+}
+
+// triggeredBy describes who triggered a build from where, for Jenkins.
+// messageID is the message that asked for the build, if known.
+func (who actor) triggeredBy(messageID string) string {
+	return fmt.Sprintf("Discord user %s (%s) in %s", who.User.Username, who.User.ID, discordLink(who.GuildID, who.ChannelID, messageID))
+}
+
+// visibleParameters returns definitions without the configured trigger
+// parameter, which is filled in by the bot and not by users.
+func (bot *Bot) visibleParameters(definitions []jenkins.ParameterDefinition) []jenkins.ParameterDefinition {
+	if bot.Config.TriggerParameter == "" {
+		return definitions
+	}
+	visible := make([]jenkins.ParameterDefinition, 0, len(definitions))
+	for _, definition := range definitions {
+		if definition.Name != bot.Config.TriggerParameter {
+			visible = append(visible, definition)
+		}
+	}
+	return visible
+}
+
+// addTriggerParameter sets the trigger parameter in values when it is
+// configured and the job declares it, and reports whether it did.
+func (bot *Bot) addTriggerParameter(who actor, definitions []jenkins.ParameterDefinition, values map[string]string) bool {
+	if len(bot.visibleParameters(definitions)) == len(definitions) {
+		return false
+	}
+	values[bot.Config.TriggerParameter] = who.triggeredBy(who.MessageID)
+	return true
+}
+
+// describeTrigger adds who triggered a build and the message tracking it to
+// the build's description in Jenkins.
+func (bot *Bot) describeTrigger(who actor, pipelineName string, number int, messageID string) {
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		return
+	}
+
+	description := "Triggered by " + who.triggeredBy(messageID)
+	if build, err := client.Build(job, number); err == nil && build.Description != "" {
+		description = build.Description + "\n" + description
+	}
+	if err := client.SetDescription(job, number, description); err != nil {
+		Logger.Printf("Error setting description of %s #%d: %v\n", pipelineName, number, err)
+	}
+}
+
+// buildTriggeredBy returns the trigger parameter of a build, or "" when it
+// has none.
+func (bot *Bot) buildTriggeredBy(pipelineName string, number int) string {
+	if bot.Config.TriggerParameter == "" {
+		return ""
+	}
+	client, job, err := bot.jenkinsJob(pipelineName)
+	if err != nil {
+		return ""
+	}
+	build, err := client.Build(job, number)
+	if err != nil {
+		return ""
+	}
+	for _, parameter := range build.Parameters() {
+		if parameter.Name == bot.Config.TriggerParameter && parameter.Value != nil {
+			return fmt.Sprint(parameter.Value)
+		}
+	}
+	return ""
+}
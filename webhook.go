@@ -0,0 +1,258 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"bot/jenkins"
+)
+
+const (
+	// DefaultWebhookAddr matches the port the Dockerfile exposes.
+	DefaultWebhookAddr = ":8080"
+	maxWebhookBody     = 1 << 20
+	// Handlers answer before posting anything, so every request is quick
+	webhookHeaderTimeout = 5 * time.Second
+	webhookReadTimeout   = 10 * time.Second
+	webhookWriteTimeout  = 10 * time.Second
+	inputWaitTimeout     = time.Minute
+	inputWaitInterval    = 3 * time.Second
+)
+
+// Build events understood by the generic /notify endpoint.
+const (
+	EventStarted  = "started"
+	EventFinished = "finished"
+	EventFailed   = "failed"
+	EventInput    = "input"
+)
+
+// NotificationRule sends events of jobs matching any of Jobs to ChannelID.
+// Job patterns use the same globs as permissions, and a rule listing
+// Instances only matches jobs on those Jenkins instances.
+type NotificationRule struct {
+	Jobs      []string `json:"jobs"`
+	Instances []string `json:"instances"`
+	ChannelID string   `json:"channel"`
+}
+
+// buildEvent is the generic payload Jenkinsfiles can post to /notify, e.g.
+//
+//	{"job": "team/service", "build": 42, "event": "finished",
+//	 "status": "SUCCESS", "url": "http://jenkins/job/team/job/service/42/"}
+//
+// Instance names the Jenkins instance the job is on. When it is left out the
+// instance is found from url, falling back to the default instance.
+type buildEvent struct {
+	Instance string `json:"instance"`
+	Job      string `json:"job"`
+	Build    int    `json:"build"`
+	Event    string `json:"event"`
+	Status   string `json:"status"`
+	URL      string `json:"url"`
+	Message  string `json:"message"`
+}
+
+// notificationPayload is the JSON format of the Jenkins Notification plugin.
+type notificationPayload struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Build struct {
+		FullURL string `json:"full_url"`
+		Number  int    `json:"number"`
+		Phase   string `json:"phase"`
+		Status  string `json:"status"`
+	} `json:"build"`
+}
+
+// startWebhookServer listens for build events on addr. Requests must carry
+// secret in the X-Webhook-Secret header or, for the Notification plugin which
+// cannot set headers, in the secret query parameter. Slow clients are cut
+// off by the server's timeouts and bodies are capped at maxWebhookBody.
+func (bot *Bot) startWebhookServer(addr, secret string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/notify", bot.requireSecret(secret, bot.handleBuildEvent))
+	mux.HandleFunc("/notify/jenkins", bot.requireSecret(secret, bot.handleNotificationPlugin))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: webhookHeaderTimeout,
+		ReadTimeout:       webhookReadTimeout,
+		WriteTimeout:      webhookWriteTimeout,
+	}
+	Logger.Println("Listening for build notifications on", addr)
+	if err := server.ListenAndServe(); err != nil {
+		Logger.Println("Webhook server stopped:", err)
+	}
+}
+
+// requireSecret rejects requests that aren't POSTs carrying the shared secret.
+func (bot *Bot) requireSecret(secret string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		given := r.Header.Get("X-Webhook-Secret")
+		if given == "" {
+			given = r.URL.Query().Get("secret")
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(secret)) != 1 {
+			Logger.Println("Rejected build notification with a bad secret from", r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxWebhookBody)
+		next(w, r)
+	}
+}
+
+func (bot *Bot) handleBuildEvent(w http.ResponseWriter, r *http.Request) {
+	var event buildEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		http.Error(w, fmt.Sprintf("invalid payload: %v", err), http.StatusBadRequest)
+		return
+	}
+	if event.Job == "" || event.Build == 0 {
+		http.Error(w, "job and build are required", http.StatusBadRequest)
+		return
+	}
+
+	switch event.Event {
+	case EventStarted, EventFinished, EventFailed, EventInput:
+	default:
+		http.Error(w, fmt.Sprintf("unknown event %q", event.Event), http.StatusBadRequest)
+		return
+	}
+	if event.Event == EventFailed && event.Status == "" {
+		event.Status = "FAILURE"
+	}
+
+	go bot.postBuildEvent(event)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (bot *Bot) handleNotificationPlugin(w http.ResponseWriter, r *http.Request) {
+	var payload notificationPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, fmt.Sprintf("invalid payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	event := buildEvent{
+		Instance: bot.instanceForURL(payload.Build.FullURL),
+		Job:      jenkins.JobNameFromURL(payload.URL),
+		Build:    payload.Build.Number,
+		Status:   payload.Build.Status,
+		URL:      payload.Build.FullURL,
+	}
+	if event.Job == "" {
+		event.Job = payload.Name
+	}
+
+	// The plugin reports QUEUED, STARTED, COMPLETED and FINALIZED; the
+	// result is only final once the build is finalized.
+	switch payload.Build.Phase {
+	case "STARTED":
+		event.Event = EventStarted
+	case "FINALIZED":
+		event.Event = EventFinished
+	default:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	go bot.postBuildEvent(event)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// postBuildEvent posts event to the channel configured for its job.
+func (bot *Bot) postBuildEvent(event buildEvent) {
+	if event.Instance == "" {
+		event.Instance = bot.instanceForURL(event.URL)
+	}
+	if _, ok := bot.instances[event.Instance]; !ok {
+		Logger.Printf("Dropping %s event of %s for unknown instance %s\n", event.Event, event.Job, event.Instance)
+		return
+	}
+
+	channelID := bot.notificationChannel(event.Instance, event.Job)
+	event.Job = bot.qualifiedName(event.Instance, event.Job)
+	if channelID == "" {
+		Logger.Printf("No notification channel configured for %s, dropping %s event\n", event.Job, event.Event)
+		return
+	}
+
+	if event.Event == EventInput {
+		bot.waitForPendingInputs(channelID, event.Job, event.Build)
+		return
+	}
+
+	var content string
+	switch event.Event {
+	case EventStarted:
+		content = fmt.Sprintf("<a:jenkinsrunning:1194478025975279687> Jenkins pipeline '%s' build #%d started", event.Job, event.Build)
+	default:
+		content = fmt.Sprintf("%s Jenkins pipeline '%s' build #%d finished: **%s**",
+			statusEmoji(&jenkins.Build{Result: event.Status}), event.Job, event.Build, event.Status)
+	}
+	if triggeredBy := bot.buildTriggeredBy(event.Job, event.Build); triggeredBy != "" {
+		content += "\nTriggered by " + triggeredBy
+	}
+	if event.Message != "" {
+		content += "\n" + event.Message
+	}
+	if event.URL != "" {
+		content += "\n" + event.URL
+	}
+
+	msg, err := bot.Session.ChannelMessageSend(channelID, content)
+	if err != nil {
+		Logger.Println("Error posting build notification:", err)
+		return
+	}
+
+	if event.Event != EventStarted && event.Status == "FAILURE" {
+		bot.postFailureSummary(channelID, msg.ID, event.Job, &jenkins.Build{Number: event.Build, URL: event.URL, Result: event.Status})
+	}
+}
+
+// waitForPendingInputs posts buttons for the input steps of a build. Jenkinsfiles
+// usually notify right before the input step, so it keeps looking for a while
+// until the input shows up.
+func (bot *Bot) waitForPendingInputs(channelID, pipelineName string, buildNumber int) {
+	announced := make(map[string]bool)
+	deadline := time.Now().Add(inputWaitTimeout)
+
+	for time.Now().Before(deadline) {
+		bot.announcePendingInputs(channelID, pipelineName, buildNumber, announced)
+		if len(announced) > 0 {
+			return
+		}
+		time.Sleep(inputWaitInterval)
+	}
+	Logger.Printf("No pending input showed up for %s #%d\n", pipelineName, buildNumber)
+}
+
+// notificationChannel returns the channel events of job on instance are
+// posted to, or "" when none is configured.
+func (bot *Bot) notificationChannel(instance, job string) string {
+	for _, rule := range bot.Config.Notifications {
+		if len(rule.Instances) > 0 && !containsString(rule.Instances, instance) {
+			continue
+		}
+		for _, pattern := range rule.Jobs {
+			if globMatch(pattern, strings.Trim(job, "/")) {
+				return rule.ChannelID
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireSecretCapsBody(t *testing.T) {
+	Logger = log.New(io.Discard, "", 0)
+	bot := &Bot{Config: &Config{}}
+	handler := bot.requireSecret("secret", bot.handleBuildEvent)
+
+	body := `{"job": "deploy", "build": 1, "event": "started", "message": "` + strings.Repeat("x", maxWebhookBody) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body))
+	req.Header.Set("X-Webhook-Secret", "secret")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}